@@ -0,0 +1,88 @@
+// Package archive exports all of a user's data as a zip archive and
+// restores such an archive into another account
+package archive
+
+import (
+	"time"
+
+	"app/model"
+)
+
+// Format and Version identify archives in their manifest. Restore reads
+// archives up to Version.
+const (
+	Format  = "accountability-export"
+	Version = 1
+)
+
+// Manifest describes an archive; it is stored as manifest.json
+type Manifest struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	UserID     uint           `json:"user_id"` // The account's ID at export, which records refer to
+	Counts     map[string]int `json:"counts"`  // Records per entity
+}
+
+// Profile is the account's profile and settings; it is stored as
+// profile.json and profile.csv
+type Profile struct {
+	Name              string    `json:"name"`
+	Username          string    `json:"username"`
+	Email             string    `json:"email"`
+	Occupation        string    `json:"occupation" validate:"max=100"`
+	About             string    `json:"about" validate:"max=1000"`
+	Timezone          string    `json:"timezone" validate:"required"`
+	QuietHoursStart   *int      `json:"quiet_hours_start" validate:"omitempty,min=0,max=23"`
+	QuietHoursEnd     *int      `json:"quiet_hours_end" validate:"omitempty,min=0,max=23"`
+	AllowNudges       bool      `json:"allow_nudges"`
+	EmailReminders    bool      `json:"email_reminders"`
+	ReminderLeadHours int       `json:"reminder_lead_hours" validate:"min=1,max=720"`
+	EmailHabits       bool      `json:"email_habits"`
+	EmailDigest       bool      `json:"email_digest"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// data holds the records of an archive
+type data struct {
+	TaskLists       []model.TaskList
+	Tasks           []model.Task
+	Labels          []model.Label
+	Goals           []model.Goal
+	Subgoals        []model.Subgoal
+	Habits          []model.Habit
+	HabitCheckIns   []model.HabitCheckIn
+	CheckIns        []model.CheckIn
+	ProgressEntries []model.ProgressEntry
+	Stakes          []model.Stake
+	LedgerEntries   []model.LedgerEntry
+	Comments        []model.Comment
+	Activities      []model.Activity
+	Notifications   []model.Notification
+}
+
+// entity is one kind of record in an archive, stored as <name>.json and
+// <name>.csv. records points to a slice of models.
+type entity struct {
+	name    string
+	records interface{}
+}
+
+func (d *data) entities() []entity {
+	return []entity{
+		{"task_lists", &d.TaskLists},
+		{"tasks", &d.Tasks},
+		{"labels", &d.Labels},
+		{"goals", &d.Goals},
+		{"subgoals", &d.Subgoals},
+		{"habits", &d.Habits},
+		{"habit_check_ins", &d.HabitCheckIns},
+		{"check_ins", &d.CheckIns},
+		{"progress_entries", &d.ProgressEntries},
+		{"stakes", &d.Stakes},
+		{"ledger_entries", &d.LedgerEntries},
+		{"comments", &d.Comments},
+		{"activities", &d.Activities},
+		{"notifications", &d.Notifications},
+	}
+}
@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// Build collects everything a user owns or wrote into a zip archive: a
+// manifest, the profile, and a JSON and a CSV file per entity. Shared lists
+// and group goals owned by others are left out.
+func Build(db *gorm.DB, userID uint, now time.Time) ([]byte, error) {
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	profile := []Profile{{
+		Name:              user.Name,
+		Username:          user.Username,
+		Email:             user.Email,
+		Occupation:        user.Occupation,
+		About:             user.About,
+		Timezone:          user.Timezone,
+		QuietHoursStart:   user.QuietHoursStart,
+		QuietHoursEnd:     user.QuietHoursEnd,
+		AllowNudges:       user.AllowNudges,
+		EmailReminders:    user.EmailReminders,
+		ReminderLeadHours: user.ReminderLeadHours,
+		EmailHabits:       user.EmailHabits,
+		EmailDigest:       user.EmailDigest,
+		CreatedAt:         user.CreatedAt,
+	}}
+
+	lists := db.Model(&model.TaskList{}).Select("id").Where("user_id = ?", userID)
+	goals := db.Model(&model.Goal{}).Select("id").Where("user_id = ?", userID)
+	habits := db.Model(&model.Habit{}).Select("id").Where("goal_id IN (?)", goals)
+
+	var d data
+	queries := map[string]*gorm.DB{
+		"task_lists":       db.Where("user_id = ?", userID),
+		"tasks":            db.Preload("Labels").Where("task_list_id IN (?)", lists),
+		"labels":           db.Where("user_id = ?", userID),
+		"goals":            db.Where("user_id = ?", userID),
+		"subgoals":         db.Where("goal_id IN (?)", goals),
+		"habits":           db.Where("goal_id IN (?)", goals),
+		"habit_check_ins":  db.Where("habit_id IN (?)", habits),
+		"check_ins":        db.Where("user_id = ?", userID),
+		"progress_entries": db.Where("user_id = ?", userID),
+		"stakes":           db.Where("user_id = ?", userID),
+		"ledger_entries":   db.Where("user_id = ?", userID),
+		"comments":         db.Where("user_id = ?", userID),
+		"activities":       db.Where("user_id = ?", userID),
+		"notifications":    db.Where("user_id = ?", userID),
+	}
+
+	manifest := Manifest{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: now,
+		UserID:     userID,
+		Counts:     map[string]int{},
+	}
+	for _, e := range d.entities() {
+		if err := queries[e.name].Order("id").Find(e.records).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	write := func(name string, fn func(w *bytes.Buffer) error) error {
+		var b bytes.Buffer
+		if err := fn(&b); err != nil {
+			return err
+		}
+		w, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b.Bytes())
+		return err
+	}
+	writeJSON := func(name string, v interface{}) error {
+		return write(name, func(w *bytes.Buffer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(v)
+		})
+	}
+	writeCSVFile := func(name string, records interface{}) error {
+		return write(name, func(w *bytes.Buffer) error {
+			return writeCSV(w, records)
+		})
+	}
+
+	if err := writeJSON("profile.json", profile[0]); err != nil {
+		return nil, err
+	}
+	if err := writeCSVFile("profile.csv", &profile); err != nil {
+		return nil, err
+	}
+	for _, e := range d.entities() {
+		if err := writeJSON(e.name+".json", e.records); err != nil {
+			return nil, err
+		}
+		if err := writeCSVFile(e.name+".csv", e.records); err != nil {
+			return nil, err
+		}
+		manifest.Counts[e.name] = count(e.records)
+	}
+	if err := writeJSON("manifest.json", manifest); err != nil {
+		return nil, err
+	}
+
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// column is a CSV column and the struct field it comes from
+type column struct {
+	name  string
+	index []int
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// columns returns a column per JSON field of a struct type. Fields of
+// embedded structs are inlined; relations and fields computed on read are
+// left out.
+func columns(t reflect.Type, index []int) []column {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			cols = append(cols, columns(f.Type, idx)...)
+			continue
+		}
+		if !f.IsExported() || f.Tag.Get("gorm") == "-" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft == timeType:
+		case ft.Kind() == reflect.Struct, ft.Kind() == reflect.Map:
+			continue
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			continue
+		}
+		cols = append(cols, column{name: name, index: idx})
+	}
+	return cols
+}
+
+// cell formats a field for CSV
+func cell(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(time.RFC3339), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		return strings.Join(v.Interface().([]string), ";"), nil
+	case v.Kind() == reflect.Slice:
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// writeCSV writes a slice of structs as CSV with a header row
+func writeCSV(w io.Writer, records interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(records))
+	cols := columns(v.Type().Elem(), nil)
+
+	writer := csv.NewWriter(w)
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	row := make([]string, len(cols))
+	for i := 0; i < v.Len(); i++ {
+		record := v.Index(i)
+		for j, col := range cols {
+			value, err := cell(record.FieldByIndex(col.index))
+			if err != nil {
+				return err
+			}
+			row[j] = value
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// count returns the length of a pointer to a slice
+func count(records interface{}) int {
+	return reflect.Indirect(reflect.ValueOf(records)).Len()
+}
@@ -0,0 +1,339 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxFileSize caps how much of a single file in an archive is read
+	maxFileSize = 64 << 20
+	// maxArchiveSize caps how much is read from all files together
+	maxArchiveSize = 128 << 20
+)
+
+// ErrInvalidArchive is returned for files that aren't a readable archive
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// ErrNotFresh is returned when restoring into an account that already has
+// lists, goals or labels
+var ErrNotFresh = errors.New("archives can only be restored into an account without lists, goals or labels")
+
+// validProfile checks the settings in an archive the same way they are
+// checked when a user changes them
+func validProfile(profile *Profile) error {
+	if err := validator.New().Struct(profile); err != nil {
+		return fmt.Errorf("%w: profile.json: %v", ErrInvalidArchive, err)
+	}
+	if (profile.QuietHoursStart == nil) != (profile.QuietHoursEnd == nil) {
+		return fmt.Errorf("%w: profile.json: quiet hours need both a start and an end", ErrInvalidArchive)
+	}
+	if _, err := time.LoadLocation(profile.Timezone); err != nil {
+		return fmt.Errorf("%w: profile.json: unknown timezone %q", ErrInvalidArchive, profile.Timezone)
+	}
+	return nil
+}
+
+// Restore recreates the lists, tasks, labels, personal goals with their
+// subgoals and habits, and the habit, check-in and progress logs of a Build
+// archive in the user's account, which must be fresh. Settings are restored
+// too; the name, username, email and password stay as they are. Comments,
+// stakes, the ledger, activity and notifications involve other people or
+// belong to the old account, so they stay in the archive only. It returns
+// the number of records restored per entity.
+func Restore(tx *gorm.DB, userID uint, archive []byte) (map[string]int, error) {
+	z, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a zip file", ErrInvalidArchive)
+	}
+	// Sizes in the zip headers can't be trusted, so the size read is checked
+	// as well
+	files := map[string]*zip.File{}
+	var total uint64
+	for _, f := range z.File {
+		total += f.UncompressedSize64
+		if f.UncompressedSize64 > maxFileSize || total > maxArchiveSize {
+			return nil, fmt.Errorf("%w: the archive is too large", ErrInvalidArchive)
+		}
+		files[f.Name] = f
+	}
+	remaining := int64(maxArchiveSize)
+	read := func(name string, v interface{}) (bool, error) {
+		f, ok := files[name]
+		if !ok {
+			return false, nil
+		}
+		r, err := f.Open()
+		if err != nil {
+			return false, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+		}
+		defer r.Close()
+		limit := int64(maxFileSize)
+		if remaining < limit {
+			limit = remaining
+		}
+		b, err := io.ReadAll(io.LimitReader(r, limit+1))
+		if err != nil {
+			return false, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+		}
+		if int64(len(b)) > limit {
+			return false, fmt.Errorf("%w: %s is too large", ErrInvalidArchive, name)
+		}
+		remaining -= int64(len(b))
+		if err := json.Unmarshal(b, v); err != nil {
+			return false, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+		}
+		return true, nil
+	}
+
+	var manifest Manifest
+	if ok, err := read("manifest.json", &manifest); err != nil {
+		return nil, err
+	} else if !ok || manifest.Format != Format {
+		return nil, fmt.Errorf("%w: manifest.json is missing", ErrInvalidArchive)
+	}
+	if manifest.Version > Version {
+		return nil, fmt.Errorf("%w: version %d is newer than this server supports", ErrInvalidArchive, manifest.Version)
+	}
+
+	var d data
+	for _, e := range d.entities() {
+		if _, err := read(e.name+".json", e.records); err != nil {
+			return nil, err
+		}
+	}
+	var profile Profile
+	hasProfile, err := read("profile.json", &profile)
+	if err != nil {
+		return nil, err
+	}
+	if hasProfile {
+		if err := validProfile(&profile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Count deleted records too, so the trash can't end up mixed in
+	for _, m := range []interface{}{&model.TaskList{}, &model.Goal{}, &model.Label{}} {
+		var n int64
+		if err := tx.Unscoped().Model(m).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, ErrNotFresh
+		}
+	}
+
+	counts := map[string]int{}
+	if hasProfile {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"occupation":          profile.Occupation,
+			"about":               profile.About,
+			"timezone":            profile.Timezone,
+			"quiet_hours_start":   profile.QuietHoursStart,
+			"quiet_hours_end":     profile.QuietHoursEnd,
+			"allow_nudges":        profile.AllowNudges,
+			"email_reminders":     profile.EmailReminders,
+			"reminder_lead_hours": profile.ReminderLeadHours,
+			"email_habits":        profile.EmailHabits,
+			"email_digest":        profile.EmailDigest,
+		}).Error; err != nil {
+			return nil, err
+		}
+		counts["profile"] = 1
+	}
+
+	// IDs in the archive mapped to the new records
+	labels := map[uint]model.Label{}
+	for _, label := range d.Labels {
+		oldID := label.ID
+		label.ID = 0
+		label.UserID = userID
+		if err := tx.Create(&label).Error; err != nil {
+			return nil, err
+		}
+		labels[oldID] = label
+	}
+	counts["labels"] = len(labels)
+
+	goals := map[uint]uint{}
+	for _, goal := range d.Goals {
+		if goal.GroupID != nil {
+			continue
+		}
+		oldID := goal.ID
+		goal.ID = 0
+		goal.UserID = userID
+		goal.Subgoals, goal.Habits, goal.Tasks = nil, nil, nil
+		if err := tx.Create(&goal).Error; err != nil {
+			return nil, err
+		}
+		goals[oldID] = goal.ID
+	}
+	counts["goals"] = len(goals)
+
+	subgoals := map[uint]uint{}
+	for _, subgoal := range d.Subgoals {
+		goalID, ok := goals[subgoal.GoalID]
+		if !ok {
+			continue
+		}
+		oldID := subgoal.ID
+		subgoal.ID = 0
+		subgoal.GoalID = goalID
+		if err := tx.Create(&subgoal).Error; err != nil {
+			return nil, err
+		}
+		subgoals[oldID] = subgoal.ID
+	}
+	counts["subgoals"] = len(subgoals)
+
+	habits := map[uint]uint{}
+	for _, habit := range d.Habits {
+		goalID, ok := goals[habit.GoalID]
+		if !ok {
+			continue
+		}
+		oldID := habit.ID
+		habit.ID = 0
+		habit.GoalID = goalID
+		if err := tx.Create(&habit).Error; err != nil {
+			return nil, err
+		}
+		habits[oldID] = habit.ID
+	}
+	counts["habits"] = len(habits)
+
+	for _, checkIn := range d.HabitCheckIns {
+		habitID, ok := habits[checkIn.HabitID]
+		if !ok {
+			continue
+		}
+		checkIn.ID = 0
+		checkIn.HabitID = habitID
+		if err := tx.Create(&checkIn).Error; err != nil {
+			return nil, err
+		}
+		counts["habit_check_ins"]++
+	}
+
+	// The partners who reviewed check-ins aren't carried over
+	for _, checkIn := range d.CheckIns {
+		goalID, ok := goals[checkIn.GoalID]
+		if !ok {
+			continue
+		}
+		checkIn.ID = 0
+		checkIn.GoalID = goalID
+		checkIn.UserID = userID
+		checkIn.ReviewerID = nil
+		if err := tx.Create(&checkIn).Error; err != nil {
+			return nil, err
+		}
+		counts["check_ins"]++
+	}
+
+	for _, entry := range d.ProgressEntries {
+		goalID, ok := goals[entry.GoalID]
+		if !ok {
+			continue
+		}
+		entry.ID = 0
+		entry.GoalID = goalID
+		entry.UserID = userID
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		counts["progress_entries"]++
+	}
+
+	lists := map[uint]uint{}
+	for _, list := range d.TaskLists {
+		oldID := list.ID
+		list.ID = 0
+		list.UserID = userID
+		list.Tasks = nil
+		if err := tx.Create(&list).Error; err != nil {
+			return nil, err
+		}
+		lists[oldID] = list.ID
+	}
+	counts["task_lists"] = len(lists)
+
+	// Create the tasks first and link subtasks once every parent exists
+	tasks := map[uint]uint{}
+	parents := map[uint]uint{}
+	for _, task := range d.Tasks {
+		listID, ok := lists[task.TaskListID]
+		if !ok {
+			continue
+		}
+		oldID := task.ID
+		taskLabels := task.Labels
+		task.ID = 0
+		task.TaskListID = listID
+		task.Labels = nil
+		task.GoalID = mapID(goals, task.GoalID)
+		task.SubgoalID = mapID(subgoals, task.SubgoalID)
+		if task.CompletedByID != nil && *task.CompletedByID == manifest.UserID {
+			task.CompletedByID = &userID
+		} else {
+			task.CompletedByID = nil
+		}
+		if task.ParentID != nil {
+			parents[oldID] = *task.ParentID
+			task.ParentID = nil
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		tasks[oldID] = task.ID
+
+		var restored []model.Label
+		for _, label := range taskLabels {
+			if l, ok := labels[label.ID]; ok {
+				restored = append(restored, l)
+			}
+		}
+		if len(restored) > 0 {
+			if err := tx.Model(&task).Association("Labels").Append(&restored); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for oldID, oldParentID := range parents {
+		parentID, ok := tasks[oldParentID]
+		if !ok {
+			continue
+		}
+		if err := tx.Model(&model.Task{}).Where("id = ?", tasks[oldID]).Update("parent_id", parentID).Error; err != nil {
+			return nil, err
+		}
+	}
+	counts["tasks"] = len(tasks)
+
+	return counts, nil
+}
+
+// mapID maps an optional ID from the archive to the new record's ID, or nil
+// if that record wasn't restored
+func mapID(ids map[uint]uint, id *uint) *uint {
+	if id == nil {
+		return nil
+	}
+	newID, ok := ids[*id]
+	if !ok {
+		return nil
+	}
+	return &newID
+}
@@ -0,0 +1,148 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMapID(t *testing.T) {
+	id := func(n uint) *uint { return &n }
+	ids := map[uint]uint{1: 10, 2: 20}
+
+	tests := []struct {
+		in   *uint
+		want *uint
+	}{
+		{nil, nil},
+		{id(1), id(10)},
+		{id(2), id(20)},
+		// Records that weren't restored, e.g. group goals
+		{id(3), nil},
+	}
+
+	for _, tt := range tests {
+		got := mapID(ids, tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mapID(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEntities(t *testing.T) {
+	// Every record type in an archive is written by Build and read by Restore
+	var d data
+	fields := reflect.TypeOf(d).NumField()
+	entities := d.entities()
+	if len(entities) != fields {
+		t.Errorf("%d entities for %d fields of data", len(entities), fields)
+	}
+	seen := map[string]bool{}
+	for _, e := range entities {
+		if seen[e.name] {
+			t.Errorf("entity %s is listed twice", e.name)
+		}
+		seen[e.name] = true
+		if reflect.TypeOf(e.records).Kind() != reflect.Ptr || reflect.TypeOf(e.records).Elem().Kind() != reflect.Slice {
+			t.Errorf("entity %s records are a %T, want a pointer to a slice", e.name, e.records)
+		}
+	}
+}
+
+func TestValidProfile(t *testing.T) {
+	hour := func(n int) *int { return &n }
+	valid := Profile{Timezone: "Europe/Berlin", ReminderLeadHours: 24}
+
+	tests := []struct {
+		name   string
+		change func(p *Profile)
+		ok     bool
+	}{
+		{"valid", func(p *Profile) {}, true},
+		{"quiet hours", func(p *Profile) { p.QuietHoursStart, p.QuietHoursEnd = hour(22), hour(7) }, true},
+		{"UTC", func(p *Profile) { p.Timezone = "UTC" }, true},
+		{"no timezone", func(p *Profile) { p.Timezone = "" }, false},
+		{"unknown timezone", func(p *Profile) { p.Timezone = "Mars/Olympus_Mons" }, false},
+		{"only a start", func(p *Profile) { p.QuietHoursStart = hour(22) }, false},
+		{"only an end", func(p *Profile) { p.QuietHoursEnd = hour(7) }, false},
+		{"hour out of range", func(p *Profile) { p.QuietHoursStart, p.QuietHoursEnd = hour(24), hour(7) }, false},
+		{"no reminder lead", func(p *Profile) { p.ReminderLeadHours = 0 }, false},
+		{"reminder lead too long", func(p *Profile) { p.ReminderLeadHours = 721 }, false},
+		{"about too long", func(p *Profile) { p.About = string(make([]byte, 1001)) }, false},
+	}
+
+	for _, tt := range tests {
+		profile := valid
+		tt.change(&profile)
+		err := validProfile(&profile)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidArchive)
+		}
+	}
+}
+
+// file is a file in a test archive. size, if set, is the uncompressed size
+// claimed in its header.
+type file struct {
+	name, body string
+	size       uint64
+}
+
+func zipFiles(t *testing.T, files ...file) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, f := range files {
+		if f.size > 0 {
+			if _, err := z.CreateRaw(&zip.FileHeader{Name: f.name, Method: zip.Store, UncompressedSize64: f.size}); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		w, err := z.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	manifest := file{name: "manifest.json", body: `{"format": "accountability-export", "version": 1}`}
+
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"not a zip file", []byte("not a zip file")},
+		{"no manifest", zipFiles(t, file{name: "tasks.json", body: "[]"})},
+		{"other format", zipFiles(t, file{name: "manifest.json", body: `{"format": "other", "version": 1}`})},
+		{"newer version", zipFiles(t, file{name: "manifest.json", body: `{"format": "accountability-export", "version": 2}`})},
+		{"broken manifest", zipFiles(t, file{name: "manifest.json", body: "{"})},
+		{"broken entity", zipFiles(t, manifest, file{name: "tasks.json", body: `{"tasks": []}`})},
+		{"invalid profile", zipFiles(t, manifest, file{name: "profile.json", body: `{"timezone": "Nowhere", "reminder_lead_hours": 24}`})},
+		{"file too large", zipFiles(t, manifest, file{name: "tasks.json", size: maxFileSize + 1})},
+		{"archive too large", zipFiles(t, manifest,
+			file{name: "tasks.json", size: maxFileSize},
+			file{name: "goals.json", size: maxFileSize},
+			file{name: "labels.json", size: 1},
+		)},
+	}
+
+	// Archives are checked before the database is touched
+	for _, tt := range tests {
+		if _, err := Restore(nil, 1, tt.archive); !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidArchive)
+		}
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"app/config"
 	"app/database"
+	"app/handler"
+	"app/jobs"
+	"app/mailer"
+	"app/outbox"
+	"app/pubsub"
 	"app/router"
+	"app/worker"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -15,12 +27,57 @@ func main() {
 	// Connect to database first, before creating multiple processes
 	database.ConnectDB()
 
+	// Relay live events through Postgres when running several instances
+	if config.Config("PUBSUB_DRIVER") == "postgres" {
+		sqlDB, err := database.DB.DB()
+		if err != nil {
+			log.Fatalf("failed to get database handle: %v", err)
+		}
+		pubsub.Use(pubsub.NewPostgres(sqlDB))
+	}
+
+	// Permanently delete trashed records after TRASH_RETENTION_DAYS (default 30, 0 disables)
+	retentionDays := 30
+	if v := config.Config("TRASH_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("invalid TRASH_RETENTION_DAYS '%s': %v", v, err)
+		}
+		retentionDays = days
+	}
+
+	// Background jobs: trash purges, stake settlement, reminders, emails, webhook deliveries and imports
+	if err := worker.Register(mailer.FromConfig(), retentionDays); err != nil {
+		log.Fatalf("failed to register jobs: %v", err)
+	}
+	handler.RegisterJobs()
+	workers := 4
+	if v := config.Config("JOB_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid JOB_WORKERS '%s'", v)
+		}
+		workers = n
+	}
+	runner, err := jobs.Start(database.DB, workers)
+	if err != nil {
+		log.Fatalf("failed to start job runner: %v", err)
+	}
+
+	// Relay domain events from the outbox to live streams and webhooks
+	handler.SubscribeToOutbox()
+	relay, err := outbox.StartRelay(database.DB)
+	if err != nil {
+		log.Fatalf("failed to start outbox relay: %v", err)
+	}
+
 	app := fiber.New(fiber.Config{
 		Prefork:       false, // Disable prefork in Docker environment
 		CaseSensitive: true,
 		StrictRouting: true,
 		ServerHeader:  "Fiber",
 		AppName:       "App Name",
+		BodyLimit:     20 * 1024 * 1024, // Exports uploaded for import can be large
 	})
 
 	app.Use(cors.New(cors.Config{
@@ -37,5 +94,26 @@ func main() {
 		port = "5000"
 	}
 	
-	log.Fatal(app.Listen(":" + port))
+	go func() {
+		if err := app.Listen(":" + port); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// Shut down gracefully: finish open requests, then let running jobs and event handlers finish
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down")
+	if err := app.ShutdownWithTimeout(10 * time.Second); err != nil {
+		log.Println("Error shutting down server: ", err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := runner.Stop(ctx); err != nil {
+		log.Println("Error stopping job runner: ", err.Error())
+	}
+	if err := relay.Stop(ctx); err != nil {
+		log.Println("Error stopping outbox relay: ", err.Error())
+	}
 }
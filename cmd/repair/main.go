@@ -0,0 +1,38 @@
+// Command repair cleans up rows orphaned by deletions made before cascading
+// deletes existed. Run it once with -dry-run to see what would change.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+
+	"app/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report orphans without changing anything")
+	flag.Parse()
+
+	database.ConnectDB()
+
+	counts, err := database.RepairOrphans(*dryRun)
+	if err != nil {
+		log.Fatalf("failed to repair orphans: %v", err)
+	}
+
+	tables := make([]string, 0, len(counts))
+	for table := range counts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	verb := "Repaired"
+	if *dryRun {
+		verb = "Would repair"
+	}
+	for _, table := range tables {
+		fmt.Printf("%s %d row(s) in %s\n", verb, counts[table], table)
+	}
+}
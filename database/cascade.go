@@ -0,0 +1,201 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// Cascade semantics when soft-deleting a record:
+//
+//   - TaskList: its tasks are deleted with it.
+//   - Task: its subtasks, and theirs, are deleted with it.
+//   - Goal: its subgoals and habits are deleted with it. Tasks linked to the
+//     goal are not deleted; they simply count as unaligned until the goal is
+//     restored, and are unlinked when the goal is purged.
+//   - User: every task list and goal of the user is deleted as above.
+//
+// Children are stamped with the parent's deletion time so that restoring the
+// parent from the trash brings back exactly what was deleted along with it.
+
+// DeleteTaskList soft-deletes a task list and its tasks
+func DeleteTaskList(tx *gorm.DB, list *model.TaskList, now time.Time) error {
+	if err := tx.Model(&model.Task{}).Where("task_list_id = ?", list.ID).
+		Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(list).Update("deleted_at", now).Error
+}
+
+// SubtaskIDs returns the IDs of the subtasks of the given tasks, their
+// subtasks and so on, including deleted ones
+func SubtaskIDs(tx *gorm.DB, ids []uint) ([]uint, error) {
+	var all []uint
+	for len(ids) > 0 {
+		var children []uint
+		if err := tx.Unscoped().Model(&model.Task{}).Where("parent_id IN ?", ids).
+			Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		all = append(all, children...)
+		ids = children
+	}
+	return all, nil
+}
+
+// DeleteTask soft-deletes a task and its subtasks
+func DeleteTask(tx *gorm.DB, task *model.Task, now time.Time) error {
+	ids, err := SubtaskIDs(tx, []uint{task.ID})
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		if err := tx.Model(&model.Task{}).Where("id IN ?", ids).
+			Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(task).Update("deleted_at", now).Error
+}
+
+// DeleteGoal soft-deletes a goal and its subgoals and habits
+func DeleteGoal(tx *gorm.DB, goal *model.Goal, now time.Time) error {
+	if err := tx.Model(&model.Subgoal{}).Where("goal_id = ?", goal.ID).
+		Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.Habit{}).Where("goal_id = ?", goal.ID).
+		Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(goal).Update("deleted_at", now).Error
+}
+
+// DeleteUser soft-deletes a user along with all of their lists, goals,
+// webhooks and imports. Data exports are deleted outright, since each holds
+// a copy of everything.
+func DeleteUser(tx *gorm.DB, user *model.User, now time.Time) error {
+	var lists []model.TaskList
+	if err := tx.Where("user_id = ?", user.ID).Find(&lists).Error; err != nil {
+		return err
+	}
+	for i := range lists {
+		if err := DeleteTaskList(tx, &lists[i], now); err != nil {
+			return err
+		}
+	}
+
+	var goals []model.Goal
+	if err := tx.Where("user_id = ?", user.ID).Find(&goals).Error; err != nil {
+		return err
+	}
+	for i := range goals {
+		if err := DeleteGoal(tx, &goals[i], now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&model.Webhook{}).Where("user_id = ?", user.ID).Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.Import{}).Where("user_id = ?", user.ID).Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&model.DataExport{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(user).Update("deleted_at", now).Error
+}
+
+// RepairOrphans applies the cascade rules above to data written before they
+// existed: live rows whose parent is deleted are deleted with the parent's
+// timestamp (or now, if the parent is gone for good), and task links to
+// purged goals or subgoals are cleared. Subtasks of deleted tasks are deleted
+// with them; those whose parent was purged move to the top of their list. It
+// returns the number of rows fixed per table; with dryRun set nothing is
+// written.
+func RepairOrphans(dryRun bool) (map[string]int64, error) {
+	relations := []struct {
+		child, fk, parent string
+	}{
+		// Parents before children so that deletions propagate all the way down
+		{"task_lists", "user_id", "users"},
+		{"goals", "user_id", "users"},
+		{"tasks", "task_list_id", "task_lists"},
+		{"subgoals", "goal_id", "goals"},
+		{"habits", "goal_id", "goals"},
+	}
+
+	counts := make(map[string]int64)
+	now := time.Now()
+	errDryRun := fmt.Errorf("dry run")
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, r := range relations {
+			res := tx.Exec(fmt.Sprintf(
+				`UPDATE %[1]s SET deleted_at = %[3]s.deleted_at FROM %[3]s
+				WHERE %[1]s.%[2]s = %[3]s.id AND %[1]s.deleted_at IS NULL AND %[3]s.deleted_at IS NOT NULL`,
+				r.child, r.fk, r.parent))
+			if res.Error != nil {
+				return res.Error
+			}
+			counts[r.child] += res.RowsAffected
+
+			res = tx.Exec(fmt.Sprintf(
+				`UPDATE %[1]s SET deleted_at = ? WHERE %[1]s.deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM %[3]s WHERE %[3]s.id = %[1]s.%[2]s)`,
+				r.child, r.fk, r.parent), now)
+			if res.Error != nil {
+				return res.Error
+			}
+			counts[r.child] += res.RowsAffected
+		}
+
+		res := tx.Exec(`UPDATE tasks SET parent_id = NULL
+			WHERE parent_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM tasks AS parents WHERE parents.id = tasks.parent_id)`)
+		if res.Error != nil {
+			return res.Error
+		}
+		counts["task_parent_links"] = res.RowsAffected
+
+		// One level of subtasks per round
+		for {
+			res = tx.Exec(`UPDATE tasks SET deleted_at = parents.deleted_at FROM tasks AS parents
+				WHERE tasks.parent_id = parents.id AND tasks.deleted_at IS NULL AND parents.deleted_at IS NOT NULL`)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				break
+			}
+			counts["tasks"] += res.RowsAffected
+		}
+
+		res = tx.Exec(`UPDATE tasks SET goal_id = NULL, subgoal_id = NULL
+			WHERE goal_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM goals WHERE goals.id = tasks.goal_id)`)
+		if res.Error != nil {
+			return res.Error
+		}
+		counts["task_goal_links"] = res.RowsAffected
+
+		res = tx.Exec(`UPDATE tasks SET subgoal_id = NULL
+			WHERE subgoal_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM subgoals WHERE subgoals.id = tasks.subgoal_id)`)
+		if res.Error != nil {
+			return res.Error
+		}
+		counts["task_subgoal_links"] = res.RowsAffected
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -51,7 +51,50 @@ func ConnectDB() {
 	fmt.Println("Connection Opened to Database")
 	
 	// Run migrations
-	err = DB.AutoMigrate(&model.User{}, &model.TaskList{}, &model.Task{}, &model.Goal{}, &model.Subgoal{}, &model.Habit{})
+	err = DB.AutoMigrate(
+		&model.User{},
+		&model.TaskList{},
+		&model.Task{},
+		&model.Label{},
+		&model.ListMember{},
+		&model.ListInvitation{},
+		&model.Goal{},
+		&model.Subgoal{},
+		&model.Habit{},
+		&model.HabitCheckIn{},
+		&model.Partnership{},
+		&model.CheckIn{},
+		&model.Comment{},
+		&model.CommentMention{},
+		&model.Reaction{},
+		&model.Group{},
+		&model.GroupMember{},
+		&model.GroupInvitation{},
+		&model.ProgressEntry{},
+		&model.Challenge{},
+		&model.ChallengeParticipant{},
+		&model.Stake{},
+		&model.LedgerEntry{},
+		&model.Activity{},
+		&model.Notification{},
+		&model.NotificationPreference{},
+		&model.Nudge{},
+		&model.ShareLink{},
+		&model.ShareSettings{},
+		&model.Follow{},
+		&model.EmailLog{},
+		&model.Webhook{},
+		&model.WebhookDelivery{},
+		&model.Job{},
+		&model.JobSchedule{},
+		&model.OutboxEvent{},
+		&model.OutboxCursor{},
+		&model.OutboxFailure{},
+		&model.ProcessedEvent{},
+		&model.CalendarFeed{},
+		&model.Import{},
+		&model.DataExport{},
+	)
 	if err != nil {
 		panic(fmt.Sprintf("failed to run database migrations: %v", err))
 	}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// FillHabitStreaks computes the current and longest streak of every habit of
+// the given goals from their check-ins
+func FillHabitStreaks(db *gorm.DB, goals []model.Goal) error {
+	var habitIDs []uint
+	for _, goal := range goals {
+		for _, habit := range goal.Habits {
+			habitIDs = append(habitIDs, habit.ID)
+		}
+	}
+	if len(habitIDs) == 0 {
+		return nil
+	}
+
+	var checkIns []model.HabitCheckIn
+	if err := db.Where("habit_id IN ?", habitIDs).Order("date ASC").Find(&checkIns).Error; err != nil {
+		return err
+	}
+	dates := make(map[uint][]time.Time)
+	for _, checkIn := range checkIns {
+		dates[checkIn.HabitID] = append(dates[checkIn.HabitID], checkIn.Date)
+	}
+
+	now := time.Now().UTC()
+	for i := range goals {
+		for j := range goals[i].Habits {
+			habit := &goals[i].Habits[j]
+			habit.CurrentStreak, habit.LongestStreak = model.HabitStreaks(habit.Frequency, dates[habit.ID], now)
+		}
+	}
+	return nil
+}
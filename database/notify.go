@@ -0,0 +1,141 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"app/model"
+	"app/outbox"
+	"app/pubsub"
+
+	"gorm.io/gorm"
+)
+
+// UserLocation returns the user's timezone, falling back to UTC
+func UserLocation(user *model.User) *time.Location {
+	if user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// quietHoursEnd returns when the user's quiet hours end if now falls inside
+// them, or now otherwise. Quiet hours may wrap past midnight, e.g. 22 to 7.
+func quietHoursEnd(user *model.User, now time.Time) time.Time {
+	if user.QuietHoursStart == nil || user.QuietHoursEnd == nil || *user.QuietHoursStart == *user.QuietHoursEnd {
+		return now
+	}
+	start, end := *user.QuietHoursStart, *user.QuietHoursEnd
+	local := now.In(UserLocation(user))
+	hour := local.Hour()
+
+	quiet := start <= hour && hour < end
+	if start > end {
+		quiet = hour >= start || hour < end
+	}
+	if !quiet {
+		return now
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end, 0, 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// NotificationEnabled reports whether the user wants notifications of a type.
+// Every type is on until the user turns it off.
+func NotificationEnabled(tx *gorm.DB, userID uint, kind string) bool {
+	var preference model.NotificationPreference
+	err := tx.Where("user_id = ? AND type = ?", userID, kind).Limit(1).Find(&preference).Error
+	return err != nil || preference.ID == 0 || preference.Enabled
+}
+
+// EventNotificationCreated is the outbox event for a notification that is
+// delivered right away
+const EventNotificationCreated = "notification.created"
+
+// Notify puts a notification in a user's inbox, held back until their quiet
+// hours are over. Nothing is sent when the user turned the type off or caused
+// the notification themselves; the returned notification is nil then. The
+// notification reaches the user's live streams through the outbox, so only
+// once tx commits.
+func Notify(tx *gorm.DB, userID uint, kind string, actorID *uint, resourceType string, resourceID uint, message string) (*model.Notification, error) {
+	if actorID != nil && *actorID == userID {
+		return nil, nil
+	}
+	if !NotificationEnabled(tx, userID, kind) {
+		return nil, nil
+	}
+
+	var user model.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	notification := model.Notification{
+		UserID:       user.ID,
+		Type:         kind,
+		ActorID:      actorID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Message:      message,
+		DeliverAt:    quietHoursEnd(&user, time.Now()),
+	}
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&notification).Error; err != nil {
+			return err
+		}
+		// Notifications held back for quiet hours are picked up from the inbox
+		if notification.DeliverAt.After(time.Now()) {
+			return nil
+		}
+		var actor uint
+		if actorID != nil {
+			actor = *actorID
+		}
+		return outbox.Emit(tx, EventNotificationCreated, actor, resourceType, resourceID, notification)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// PublishNotification pushes a new notification to its recipient's streams
+func PublishNotification(notification *model.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return pubsub.Publish(pubsub.Event{
+		Type:         EventNotificationCreated,
+		ResourceType: notification.ResourceType,
+		ResourceID:   notification.ResourceID,
+		Data:         data,
+		UserIDs:      []uint{notification.UserID},
+	})
+}
+
+// NotifyDeadlines reminds owners of open goals whose deadline falls within
+// the given window. Each goal is only reminded about once.
+func NotifyDeadlines(now time.Time, within time.Duration) error {
+	var goals []model.Goal
+	if err := DB.Where("completed = ? AND deadline > ? AND deadline <= ?", false, now, now.Add(within)).
+		Where("id NOT IN (?)", DB.Model(&model.Notification{}).Select("resource_id").
+			Where("type = ? AND resource_type = ?", model.NotificationDeadline, model.ResourceGoal)).
+		Find(&goals).Error; err != nil {
+		return err
+	}
+
+	for _, goal := range goals {
+		if _, err := Notify(DB, goal.UserID, model.NotificationDeadline, nil, model.ResourceGoal, goal.ID,
+			"The deadline for \""+goal.Name+"\" is coming up"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"app/config"
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StakeDisputeWindow returns how long a referee has to reject a claimed
+// completion before it stands, from STAKE_DISPUTE_HOURS (default 72)
+func StakeDisputeWindow() time.Duration {
+	hours := 72
+	if v := config.Config("STAKE_DISPUTE_HOURS"); v != "" {
+		if h, err := strconv.Atoi(v); err == nil && h >= 0 {
+			hours = h
+		} else {
+			log.Printf("invalid STAKE_DISPUTE_HOURS '%s', using %d", v, hours)
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// ClaimStake marks an active stake as claimed and opens the dispute window
+func ClaimStake(tx *gorm.DB, stake *model.Stake, now time.Time) error {
+	until := now.Add(StakeDisputeWindow())
+	stake.Status = model.StakeClaimed
+	stake.ClaimedAt = &now
+	stake.DisputeUntil = &until
+	return tx.Save(stake).Error
+}
+
+// ForfeitStake marks a stake as forfeited and records it in the owner's ledger
+func ForfeitStake(tx *gorm.DB, stake *model.Stake, goal *model.Goal, reason string, now time.Time) error {
+	stake.Status = model.StakeForfeited
+	stake.ResolvedAt = &now
+	if err := tx.Save(stake).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&model.LedgerEntry{
+		UserID:   stake.UserID,
+		StakeID:  stake.ID,
+		GoalID:   goal.ID,
+		GoalName: goal.Name,
+		Amount:   stake.Amount,
+		Unit:     stake.Unit,
+		Reason:   reason,
+	}).Error; err != nil {
+		return err
+	}
+	_, err := Notify(tx, stake.UserID, model.NotificationStake, nil, model.ResourceGoal, goal.ID,
+		"You forfeited your stake on \""+goal.Name+"\"")
+	return err
+}
+
+// SettleStakes resolves stakes that no longer need anyone to act on them:
+// active stakes past their goal's deadline are forfeited (or claimed, if the
+// goal was completed without a claim), and claims the referee didn't reject
+// within the dispute window are kept. Deleting a goal doesn't get its owner
+// out of a stake.
+func SettleStakes(now time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so that owners and referees acting at the same time wait
+		var active []model.Stake
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND goal_id IN (?)", model.StakeActive,
+				tx.Unscoped().Model(&model.Goal{}).Select("id").Where("deadline < ?", now)).
+			Find(&active).Error; err != nil {
+			return err
+		}
+		for i := range active {
+			var goal model.Goal
+			if err := tx.Unscoped().First(&goal, active[i].GoalID).Error; err != nil {
+				return err
+			}
+			var err error
+			if goal.Completed {
+				err = ClaimStake(tx, &active[i], now)
+			} else {
+				err = ForfeitStake(tx, &active[i], &goal, model.ForfeitDeadlineMissed, now)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&model.Stake{}).
+			Where("status = ? AND dispute_until < ?", model.StakeClaimed, now).
+			Updates(map[string]interface{}{"status": model.StakeKept, "resolved_at": now}).Error
+	})
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// RestoreTaskList undeletes a task list along with the tasks that were
+// deleted in the same operation (i.e. carry the same deletion timestamp)
+func RestoreTaskList(tx *gorm.DB, list *model.TaskList) error {
+	deletedAt := list.DeletedAt
+	if err := tx.Unscoped().Model(&model.Task{}).
+		Where("task_list_id = ? AND deleted_at = ?", list.ID, deletedAt).
+		Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(list).Update("deleted_at", nil).Error
+}
+
+// RestoreTask undeletes a task along with the subtasks that were deleted in
+// the same operation
+func RestoreTask(tx *gorm.DB, task *model.Task) error {
+	ids, err := SubtaskIDs(tx, []uint{task.ID})
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		if err := tx.Unscoped().Model(&model.Task{}).
+			Where("id IN ? AND deleted_at = ?", ids, task.DeletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Model(task).Update("deleted_at", nil).Error
+}
+
+// RestoreGoal undeletes a goal along with the subgoals and habits that were
+// deleted in the same operation
+func RestoreGoal(tx *gorm.DB, goal *model.Goal) error {
+	deletedAt := goal.DeletedAt
+	if err := tx.Unscoped().Model(&model.Subgoal{}).
+		Where("goal_id = ? AND deleted_at = ?", goal.ID, deletedAt).
+		Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&model.Habit{}).
+		Where("goal_id = ? AND deleted_at = ?", goal.ID, deletedAt).
+		Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(goal).Update("deleted_at", nil).Error
+}
+
+// purgeInteractions permanently deletes the comments and reactions on the
+// given resources. ids is a slice of IDs or a subquery selecting them.
+func purgeInteractions(tx *gorm.DB, resourceType string, ids interface{}) error {
+	comments := tx.Unscoped().Model(&model.Comment{}).Select("id").
+		Where("resource_type = ? AND resource_id IN (?)", resourceType, ids)
+	if err := tx.Where("comment_id IN (?)", comments).Delete(&model.CommentMention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("resource_type = ? AND resource_id IN (?)", resourceType, ids).
+		Delete(&model.Comment{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("resource_type = ? AND resource_id IN (?)", resourceType, ids).
+		Delete(&model.Reaction{}).Error
+}
+
+// PurgeTask permanently deletes a task and its subtasks
+func PurgeTask(tx *gorm.DB, id uint) error {
+	ids, err := SubtaskIDs(tx, []uint{id})
+	if err != nil {
+		return err
+	}
+	ids = append(ids, id)
+	if err := purgeInteractions(tx, model.ResourceTask, ids); err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&model.Task{}, ids).Error
+}
+
+// PurgeTaskList permanently deletes a task list and all of its tasks
+func PurgeTaskList(tx *gorm.DB, id uint) error {
+	tasks := tx.Unscoped().Model(&model.Task{}).Select("id").Where("task_list_id = ?", id)
+	if err := purgeInteractions(tx, model.ResourceTask, tasks); err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("task_list_id = ?", id).Delete(&model.Task{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("list_id = ?", id).Delete(&model.CalendarFeed{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&model.TaskList{}, id).Error
+}
+
+// PurgeGoal permanently deletes a goal with its subgoals and habits. Tasks
+// linked to it are kept but unlinked.
+func PurgeGoal(tx *gorm.DB, id uint) error {
+	if err := tx.Unscoped().Model(&model.Task{}).Where("goal_id = ?", id).
+		Updates(map[string]interface{}{"goal_id": nil, "subgoal_id": nil}).Error; err != nil {
+		return err
+	}
+	if err := purgeInteractions(tx, model.ResourceGoal, []uint{id}); err != nil {
+		return err
+	}
+	subgoals := tx.Unscoped().Model(&model.Subgoal{}).Select("id").Where("goal_id = ?", id)
+	if err := purgeInteractions(tx, model.ResourceSubgoal, subgoals); err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.Subgoal{}).Error; err != nil {
+		return err
+	}
+	goalHabits := tx.Unscoped().Model(&model.Habit{}).Select("id").Where("goal_id = ?", id)
+	if err := tx.Unscoped().Where("habit_id IN (?)", goalHabits).Delete(&model.HabitCheckIn{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.ChallengeParticipant{}).Where("habit_id IN (?)", goalHabits).
+		Update("habit_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.ChallengeParticipant{}).Where("goal_id = ?", id).
+		Update("goal_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.Habit{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.CheckIn{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.ProgressEntry{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.Stake{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.ShareLink{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("goal_id = ?", id).Delete(&model.ShareSettings{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&model.Goal{}, id).Error
+}
+
+// PurgeTrash permanently deletes goals, task lists, tasks, subgoals and
+// habits that were soft-deleted before the cutoff
+func PurgeTrash(cutoff time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var listIDs []uint
+		if err := tx.Unscoped().Model(&model.TaskList{}).
+			Where("deleted_at < ?", cutoff).Pluck("id", &listIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range listIDs {
+			if err := PurgeTaskList(tx, id); err != nil {
+				return err
+			}
+		}
+
+		var goalIDs []uint
+		if err := tx.Unscoped().Model(&model.Goal{}).
+			Where("deleted_at < ?", cutoff).Pluck("id", &goalIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range goalIDs {
+			if err := PurgeGoal(tx, id); err != nil {
+				return err
+			}
+		}
+
+		var subgoalIDs []uint
+		if err := tx.Unscoped().Model(&model.Subgoal{}).
+			Where("deleted_at < ?", cutoff).Pluck("id", &subgoalIDs).Error; err != nil {
+			return err
+		}
+		if len(subgoalIDs) > 0 {
+			if err := tx.Unscoped().Model(&model.Task{}).Where("subgoal_id IN ?", subgoalIDs).
+				Update("subgoal_id", nil).Error; err != nil {
+				return err
+			}
+			if err := purgeInteractions(tx, model.ResourceSubgoal, subgoalIDs); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&model.Subgoal{}, subgoalIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		habits := tx.Unscoped().Model(&model.Habit{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := tx.Unscoped().Where("habit_id IN (?)", habits).Delete(&model.HabitCheckIn{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ChallengeParticipant{}).Where("habit_id IN (?)", habits).
+			Update("habit_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&model.Habit{}).Error; err != nil {
+			return err
+		}
+		tasks := tx.Unscoped().Model(&model.Task{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := purgeInteractions(tx, model.ResourceTask, tasks); err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&model.Task{}).Error
+	})
+}
@@ -0,0 +1,305 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"app/jobs"
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// webhookMaxAttempts is how often a delivery is tried before it fails
+	webhookMaxAttempts = 8
+	// webhookRetryBase is the wait before the first retry; it doubles with
+	// every further attempt
+	webhookRetryBase = time.Minute
+	// webhookDisableAfter is how many failed attempts in a row disable a webhook
+	webhookDisableAfter = 20
+	// webhookResponseLimit caps how much of a response body is kept
+	webhookResponseLimit = 1024
+)
+
+// ErrInternalAddress is returned for webhook hosts that resolve to an
+// address on the server's own network
+var ErrInternalAddress = errors.New("webhook address is not public")
+
+// webhookClient only connects to public addresses and does not follow
+// redirects, so webhooks cannot reach services on the internal network
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("%w: %s", ErrInternalAddress, host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// publicIP reports whether an address is reachable on the public internet
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// CheckWebhookHost resolves a webhook host and returns ErrInternalAddress if
+// any of its addresses is not public. Deliveries check the address again
+// when connecting, since DNS can change after a webhook is saved.
+func CheckWebhookHost(ctx context.Context, host string) error {
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !publicIP(ip.IP) {
+			return fmt.Errorf("%w: %s", ErrInternalAddress, ip.IP)
+		}
+	}
+	return nil
+}
+
+// JobDeliverWebhook is the job type that makes one delivery attempt
+const JobDeliverWebhook = "webhook.deliver"
+
+// DeliverWebhookPayload is the payload of a JobDeliverWebhook job
+type DeliverWebhookPayload struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// QueueDelivery saves a new delivery and enqueues its first attempt
+func QueueDelivery(tx *gorm.DB, delivery *model.WebhookDelivery) error {
+	if err := tx.Create(delivery).Error; err != nil {
+		return err
+	}
+	return enqueueAttempt(tx, delivery)
+}
+
+// enqueueAttempt enqueues a job for a delivery's next attempt
+func enqueueAttempt(tx *gorm.DB, delivery *model.WebhookDelivery) error {
+	_, err := jobs.Enqueue(tx, JobDeliverWebhook, DeliverWebhookPayload{DeliveryID: delivery.ID},
+		jobs.Options{RunAt: delivery.NextAttemptAt})
+	return err
+}
+
+// ResumeWebhook enqueues the pending deliveries of a webhook that was
+// disabled, which were held back in the meantime
+func ResumeWebhook(tx *gorm.DB, webhook *model.Webhook, now time.Time) error {
+	var deliveries []model.WebhookDelivery
+	if err := tx.Where("webhook_id = ? AND status = ?", webhook.ID, model.DeliveryPending).Find(&deliveries).Error; err != nil {
+		return err
+	}
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		delivery.NextAttemptAt = now
+		if err := tx.Model(delivery).Update("next_attempt_at", now).Error; err != nil {
+			return err
+		}
+		if err := enqueueAttempt(tx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueueWebhooks queues an event for every active webhook of the user that
+// subscribes to it
+func QueueWebhooks(tx *gorm.DB, userID uint, event string, data interface{}) error {
+	var webhooks []model.Webhook
+	if err := tx.Where("user_id = ? AND active = ?", userID, true).Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(map[string]interface{}{
+				"event":      event,
+				"created_at": now,
+				"data":       data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := QueueDelivery(tx, &model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        model.DeliveryPending,
+			NextAttemptAt: now,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SignWebhook returns the signature sent with a payload: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<payload>" keyed with the webhook's secret
+func SignWebhook(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliverWebhook makes a delivery's next attempt if it is still pending,
+// due and its webhook is active. The delivery is leased first so that a
+// duplicate job can't send it twice.
+func DeliverWebhook(id uint, now time.Time) error {
+	res := DB.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, model.DeliveryPending, now).
+		Where("webhook_id IN (?)", DB.Model(&model.Webhook{}).Select("id").Where("active = ?", true)).
+		Update("next_attempt_at", now.Add(webhookClient.Timeout+time.Minute))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	var delivery model.WebhookDelivery
+	if err := DB.First(&delivery, id).Error; err != nil {
+		return err
+	}
+	return deliverWebhook(&delivery, now)
+}
+
+// deliverWebhook sends a delivery once and records the outcome: success,
+// a retry with exponential backoff, or failure once it has run out of
+// attempts. Failing too often in a row disables the webhook.
+func deliverWebhook(delivery *model.WebhookDelivery, now time.Time) error {
+	var webhook model.Webhook
+	if err := DB.First(&webhook, delivery.WebhookID).Error; err != nil {
+		return err
+	}
+
+	code, body, err := postWebhook(&webhook, delivery, now)
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.ResponseBody = body
+	delivery.Error = ""
+	succeeded := err == nil && code >= 200 && code < 300
+	if succeeded {
+		delivery.Status = model.DeliverySucceeded
+		delivery.DeliveredAt = &now
+	} else {
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Error = fmt.Sprintf("endpoint responded with %d", code)
+		}
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = model.DeliveryFailed
+		} else {
+			delivery.NextAttemptAt = now.Add(webhookRetryBase << (delivery.Attempts - 1))
+		}
+	}
+
+	disabled := false
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(delivery).Error; err != nil {
+			return err
+		}
+		if delivery.Status == model.DeliveryPending {
+			if err := enqueueAttempt(tx, delivery); err != nil {
+				return err
+			}
+		}
+		// Deliveries are sent concurrently, so count failures in SQL
+		if succeeded {
+			return tx.Model(&webhook).Update("failure_count", 0).Error
+		}
+		if err := tx.Model(&webhook).Update("failure_count", gorm.Expr("failure_count + 1")).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&model.Webhook{}).
+			Where("id = ? AND active = ? AND failure_count >= ?", webhook.ID, true, webhookDisableAfter).
+			Updates(map[string]interface{}{"active": false, "disabled_at": now})
+		disabled = res.RowsAffected > 0
+		return res.Error
+	}); err != nil {
+		// Release the lease so that the job's retry can make the attempt again
+		if releaseErr := DB.Model(&model.WebhookDelivery{}).
+			Where("id = ? AND status = ?", delivery.ID, model.DeliveryPending).
+			Update("next_attempt_at", now).Error; releaseErr != nil {
+			log.Println("Error releasing webhook delivery: ", releaseErr.Error())
+		}
+		return err
+	}
+
+	if disabled {
+		if _, err := Notify(DB, webhook.UserID, model.NotificationWebhook, nil, model.ResourceWebhook, webhook.ID,
+			"Your webhook to "+webhook.URL+" was disabled after failing repeatedly"); err != nil {
+			log.Println("Error sending notification: ", err.Error())
+		}
+	}
+	return nil
+}
+
+// postWebhook posts a delivery's payload to its webhook and returns the
+// response code and the start of the response body
+func postWebhook(webhook *model.Webhook, delivery *model.WebhookDelivery, now time.Time) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Accountability-Webhook/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(webhook.Secret, timestamp, payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	return resp.StatusCode, cleanResponseBody(body), nil
+}
+
+// cleanResponseBody makes a response body safe to store in a text column:
+// NUL bytes and invalid UTF-8, e.g. a character cut off at the limit, are
+// dropped
+func cleanResponseBody(body []byte) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(string(body), "\x00", ""), "")
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// Expected signatures computed with
+	// printf '%s' "<timestamp>.<payload>" | openssl dgst -sha256 -hmac <secret>
+	tests := []struct {
+		secret    string
+		timestamp int64
+		payload   string
+		want      string
+	}{
+		{"secret", 1700000000, `{"event":"task.completed"}`, "8476087d712b027a668e5e7019c04b9b70e5a33b56bba5e57c8aa39aa44ea5e3"},
+		{"secret", 1700000001, `{"event":"task.completed"}`, "ca0c31693f0989d591fdb15a414a97524a041dec1c19376947fb726d011a9cce"},
+		{"", 0, "", "b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.payload)); got != tt.want {
+			t.Errorf("SignWebhook(%q, %d, %q) = %s, want %s", tt.secret, tt.timestamp, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false}, // Cloud metadata endpoints
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if ip == nil {
+			t.Fatalf("invalid test address %q", tt.ip)
+		}
+		if got := publicIP(ip); got != tt.want {
+			t.Errorf("publicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	for _, url := range []string{"http://127.0.0.1:1/", "http://[::1]:1/", "http://169.254.169.254/"} {
+		resp, err := webhookClient.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if !errors.Is(err, ErrInternalAddress) {
+			t.Errorf("GET %s: got %v, want %v", url, err, ErrInternalAddress)
+		}
+	}
+}
+
+func TestCleanResponseBody(t *testing.T) {
+	tests := []struct {
+		body []byte
+		want string
+	}{
+		{[]byte("ok"), "ok"},
+		{[]byte("caf\xc3\xa9"), "café"},
+		{[]byte("nul\x00byte"), "nulbyte"},
+		// A character cut off at the response limit
+		{[]byte("caf\xc3"), "caf"},
+		{[]byte("\xff\xfebinary"), "binary"},
+		{[]byte{}, ""},
+	}
+
+	for _, tt := range tests {
+		got := cleanResponseBody(tt.body)
+		if got != tt.want {
+			t.Errorf("cleanResponseBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("cleanResponseBody(%q) = %q is not valid UTF-8", tt.body, got)
+		}
+	}
+}
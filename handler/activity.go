@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"strconv"
+
+	"app/database"
+	"app/model"
+	"app/outbox"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxPageSize caps the limit query parameter of paginated feeds
+const maxPageSize = 100
+
+// ActivityItem is an activity as shown in a feed
+type ActivityItem struct {
+	model.Activity
+	Username string `json:"username"`
+}
+
+// ActivityEvent is the payload of the outbox events recordActivity emits
+type ActivityEvent struct {
+	ActivityID uint   `json:"activity_id"`
+	GoalID     *uint  `json:"goal_id"`
+	Summary    string `json:"summary"`
+}
+
+// recordActivity adds an entry to the user's activity history and emits it
+// as a "<resource type>.<action>" outbox event, from which it reaches live
+// streams and webhooks. It runs in the transaction that made the change.
+func recordActivity(tx *gorm.DB, userID uint, resourceType string, resourceID uint, goalID *uint, action, summary string) error {
+	activity := model.Activity{
+		UserID:       userID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		GoalID:       goalID,
+		Action:       action,
+		Summary:      summary,
+	}
+	if err := tx.Create(&activity).Error; err != nil {
+		return err
+	}
+	return outbox.Emit(tx, resourceType+"."+action, userID, resourceType, resourceID, ActivityEvent{
+		ActivityID: activity.ID,
+		GoalID:     goalID,
+		Summary:    summary,
+	})
+}
+
+// completionAction returns the activity action for a completed flag
+func completionAction(completed bool) string {
+	if completed {
+		return model.ActivityCompleted
+	}
+	return model.ActivityReopened
+}
+
+// pageParams reads the page (from 1) and limit query parameters of a feed
+func pageParams(c *fiber.Ctx) (int, int) {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return page, limit
+}
+
+// activityResponse runs a feed query for one page and writes the response
+func activityResponse(c *fiber.Ctx, query *gorm.DB) error {
+	page, limit := pageParams(c)
+	items := []ActivityItem{}
+	// Fetch one extra row to know whether there is a next page
+	if err := query.Select("activities.*, users.username").
+		Joins("JOIN users ON users.id = activities.user_id").
+		Order("activities.created_at DESC, activities.id DESC").
+		Offset((page - 1) * limit).Limit(limit + 1).
+		Scan(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve activity",
+			"errors":  err.Error(),
+		})
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Activity retrieved successfully",
+		"data": fiber.Map{
+			"items":    items,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+		},
+	})
+}
+
+// GetActivity returns the user's own activity, newest first
+func GetActivity(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	return activityResponse(c, database.DB.Model(&model.Activity{}).
+		Where("activities.user_id = ?", userID))
+}
+
+// GetActivityFeed returns what the user's partners and fellow group members
+// did on goals the user can see. Lists and tasks are never shared here.
+func GetActivityFeed(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	partners := db.Model(&model.Partnership{}).
+		Select("CASE WHEN requester_id = ? THEN addressee_id ELSE requester_id END", userID).
+		Where("(requester_id = ? OR addressee_id = ?) AND status = ?", userID, userID, model.PartnershipAccepted)
+	groups := db.Model(&model.GroupMember{}).Select("group_id").Where("user_id = ?", userID)
+
+	query := db.Model(&model.Activity{}).
+		Joins("JOIN goals ON goals.id = activities.goal_id AND goals.deleted_at IS NULL").
+		Where("activities.user_id <> ?", userID).
+		Where("(goals.group_id IN (?)) OR (goals.group_id IS NULL AND goals.user_id IN (?) AND goals.visibility IN ?)",
+			groups, partners, []string{model.VisibilityPartners, model.VisibilityPublic})
+	if v := c.Query("group_id"); v != "" {
+		query = query.Where("goals.group_id = ?", v)
+	}
+
+	return activityResponse(c, query)
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"app/database"
+	"app/jobs"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetJobs lists background jobs, newest first, one page at a time. The
+// list can be filtered with ?status= and ?type=, and comes with the number
+// of jobs per status.
+func GetJobs(c *fiber.Ctx) error {
+	db := database.DB
+	query := db.Model(&model.Job{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	page, limit := pageParams(c)
+	items := []model.Job{}
+	// Fetch one extra row to know whether there is a next page
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).Limit(limit + 1).
+		Find(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve jobs",
+			"errors":  err.Error(),
+		})
+	}
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	type StatusCount struct {
+		Status string
+		Count  int64
+	}
+	var rows []StatusCount
+	if err := db.Model(&model.Job{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't count jobs",
+			"errors":  err.Error(),
+		})
+	}
+	counts := map[string]int64{model.JobQueued: 0, model.JobRunning: 0, model.JobSucceeded: 0, model.JobFailed: 0}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Jobs retrieved successfully",
+		"data": fiber.Map{
+			"items":    items,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+			"counts":   counts,
+		},
+	})
+}
+
+// GetJob returns a single background job
+func GetJob(c *fiber.Ctx) error {
+	var job model.Job
+	if err := database.DB.First(&job, c.Params("job_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Job not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Job retrieved successfully",
+		"data":    job,
+	})
+}
+
+// RetryJob queues a failed job to run again
+func RetryJob(c *fiber.Ctx) error {
+	db := database.DB
+	var job model.Job
+	if err := db.First(&job, c.Params("job_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Job not found",
+		})
+	}
+	if job.Status != model.JobFailed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only failed jobs can be retried",
+			"data":    nil,
+		})
+	}
+	if job.UniqueKey != nil {
+		var pending int64
+		db.Model(&model.Job{}).Where("unique_key = ? AND finished_at IS NULL", *job.UniqueKey).Count(&pending)
+		if pending > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Another run of this job is already queued",
+				"data":    nil,
+			})
+		}
+	}
+
+	if err := jobs.Retry(db, &job); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retry job",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Job queued",
+		"data":    job,
+	})
+}
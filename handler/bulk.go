@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// BulkTaskResult reports the outcome of a bulk operation for a single task
+type BulkTaskResult struct {
+	TaskID  uint   `json:"task_id"`
+	Status  string `json:"status"` // "success" or "error"
+	Message string `json:"message,omitempty"`
+}
+
+// BulkUpdateTasks applies one operation to many tasks in a single
+// transaction. Tasks that don't exist or don't belong to the user are
+// skipped and reported; the rest are updated together.
+func BulkUpdateTasks(c *fiber.Ctx) error {
+	type BulkTaskInput struct {
+		TaskIDs   []uint     `json:"task_ids" validate:"required,min=1,max=500"`
+		Operation string     `json:"operation" validate:"required,oneof=complete uncomplete delete move add_label remove_label set_due_date"`
+		ListID    uint       `json:"list_id"`                 // Target list for "move"
+		Label     string     `json:"label" validate:"max=50"` // Label name for "add_label" and "remove_label"
+		DueDate   *time.Time `json:"due_date"`                // New due date for "set_due_date", null clears it
+	}
+
+	var input BulkTaskInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Validate input
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+	input.Label = strings.TrimSpace(input.Label)
+	if (input.Operation == "add_label" || input.Operation == "remove_label") && input.Label == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A label is required for this operation",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+
+	// Verify the target list for moves up front
+	if input.Operation == "move" {
+		var target model.TaskList
+		if err := db.First(&target, "id = ?", input.ListID).Error; err != nil || !hasRole(listRole(db, &target, userID), model.RoleEditor) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Target list not found",
+				"data":    nil,
+			})
+		}
+	}
+
+	action := model.ActivityUpdated
+	switch input.Operation {
+	case "complete":
+		action = model.ActivityCompleted
+	case "uncomplete":
+		action = model.ActivityReopened
+	case "delete":
+		action = model.ActivityDeleted
+	}
+
+	results := make([]BulkTaskResult, 0, len(input.TaskIDs))
+	succeeded := 0
+	now := time.Now()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var label model.Label
+		switch input.Operation {
+		case "add_label":
+			if err := tx.Where(model.Label{UserID: userID, Name: input.Label}).FirstOrCreate(&label).Error; err != nil {
+				return err
+			}
+		case "remove_label":
+			if err := tx.Where(model.Label{UserID: userID, Name: input.Label}).Limit(1).Find(&label).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, taskID := range input.TaskIDs {
+			var task model.Task
+			if err := tx.First(&task, "id = ?", taskID).Error; err != nil {
+				results = append(results, BulkTaskResult{TaskID: taskID, Status: "error", Message: "Task not found"})
+				continue
+			}
+
+			// Ensure the logged-in user may edit the task's list
+			var taskList model.TaskList
+			if err := tx.First(&taskList, "id = ?", task.TaskListID).Error; err != nil || !hasRole(listRole(tx, &taskList, userID), model.RoleEditor) {
+				results = append(results, BulkTaskResult{TaskID: taskID, Status: "error", Message: "You are not authorized to update this task"})
+				continue
+			}
+
+			var err error
+			switch input.Operation {
+			case "complete":
+				if !task.Completed {
+					err = tx.Model(&task).Updates(map[string]interface{}{"completed": true, "completed_by_id": userID, "completed_at": now}).Error
+				}
+			case "uncomplete":
+				err = tx.Model(&task).Updates(map[string]interface{}{"completed": false, "completed_by_id": nil, "completed_at": nil}).Error
+			case "delete":
+				err = database.DeleteTask(tx, &task, now)
+			case "move":
+				// Subtasks move along with their task; a moved subtask
+				// leaves its parent behind
+				var ids []uint
+				if ids, err = database.SubtaskIDs(tx, []uint{task.ID}); err == nil && len(ids) > 0 {
+					err = tx.Unscoped().Model(&model.Task{}).Where("id IN ?", ids).
+						Update("task_list_id", input.ListID).Error
+				}
+				if err == nil && task.TaskListID != input.ListID {
+					err = tx.Model(&task).Updates(map[string]interface{}{"task_list_id": input.ListID, "parent_id": nil}).Error
+				}
+			case "add_label":
+				err = tx.Model(&task).Association("Labels").Append(&label)
+			case "remove_label":
+				if label.ID != 0 {
+					err = tx.Model(&task).Association("Labels").Delete(&label)
+				}
+			case "set_due_date":
+				err = tx.Model(&task).Update("due_date", input.DueDate).Error
+			}
+			if err != nil {
+				return err
+			}
+			if err := recordActivity(tx, userID, model.ResourceTask, task.ID, nil, action, task.Text); err != nil {
+				return err
+			}
+
+			results = append(results, BulkTaskResult{TaskID: taskID, Status: "success"})
+			succeeded++
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update tasks",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": strconv.Itoa(succeeded) + " of " + strconv.Itoa(len(input.TaskIDs)) + " tasks updated",
+		"data": fiber.Map{
+			"results":   results,
+			"succeeded": succeeded,
+			"failed":    len(input.TaskIDs) - succeeded,
+		},
+	})
+}
+
+// ClearCompletedTasks deletes every completed task in a list
+func ClearCompletedTasks(c *fiber.Ctx) error {
+	listID, err := strconv.ParseUint(c.Params("list_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid task list ID format",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var list model.TaskList
+	if err := db.First(&list, "id = ?", uint(listID)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No task list found with the provided ID",
+			"errors":  err.Error(),
+		})
+	}
+
+	if !hasRole(listRole(db, &list, userID), model.RoleEditor) {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to update this task list",
+			"data":    nil,
+		})
+	}
+
+	var cleared []model.Task
+	if err := db.Where("task_list_id = ? AND completed = ?", list.ID, true).Find(&cleared).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't clear completed tasks",
+			"errors":  err.Error(),
+		})
+	}
+	if len(cleared) == 0 {
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "Completed tasks cleared",
+			"data":    fiber.Map{"deleted": 0},
+		})
+	}
+
+	// Stamp all cleared tasks with the same time so they can be found together in the trash
+	ids := make([]uint, len(cleared))
+	for i, task := range cleared {
+		ids[i] = task.ID
+	}
+	var deleted int64
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Task{}).Where("id IN ?", ids).Update("deleted_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		deleted = res.RowsAffected
+		for _, task := range cleared {
+			if err := recordActivity(tx, userID, model.ResourceTask, task.ID, nil, model.ActivityDeleted, task.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't clear completed tasks",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Completed tasks cleared",
+		"data":    fiber.Map{"deleted": deleted},
+	})
+}
@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"app/database"
+	"app/ical"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// calendarUIDDomain qualifies the UIDs of calendar entries. UIDs only depend
+// on the entry's ID, so calendar apps update entries instead of duplicating
+// them.
+const calendarUIDDomain = "@accountability"
+
+// CalendarFeedItem is a calendar feed with its subscription URL
+type CalendarFeedItem struct {
+	model.CalendarFeed
+	URL string `json:"url"`
+}
+
+func calendarFeedItem(c *fiber.Ctx, feed *model.CalendarFeed) CalendarFeedItem {
+	return CalendarFeedItem{
+		CalendarFeed: *feed,
+		URL:          c.BaseURL() + "/api/public/calendar/" + feed.Token + ".ics",
+	}
+}
+
+// CreateCalendarFeed creates a secret subscription URL for the user's goal
+// deadlines, milestones, habits and due tasks, or for a part of them
+func CreateCalendarFeed(c *fiber.Ctx) error {
+	type CalendarFeedInput struct {
+		Name   string `json:"name" validate:"max=100"`
+		Scope  string `json:"scope" validate:"omitempty,oneof=all goals tasks list"`
+		ListID *uint  `json:"list_id"` // Required for the list scope
+	}
+
+	var input CalendarFeedInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	feed := model.CalendarFeed{UserID: userID, Name: input.Name, Scope: input.Scope}
+	if feed.Scope == "" {
+		feed.Scope = model.CalendarAll
+	}
+	if feed.Scope == model.CalendarList {
+		var list model.TaskList
+		if input.ListID == nil || db.First(&list, *input.ListID).Error != nil || listRole(db, &list, userID) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "A list feed needs a list you have access to",
+				"data":    nil,
+			})
+		}
+		feed.ListID = &list.ID
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't generate feed token",
+			"errors":  err.Error(),
+		})
+	}
+	feed.Token = token
+
+	if err := db.Create(&feed).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create calendar feed",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Calendar feed created",
+		"data":    calendarFeedItem(c, &feed),
+	})
+}
+
+// GetCalendarFeeds lists the user's calendar feeds that haven't been revoked
+func GetCalendarFeeds(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var feeds []model.CalendarFeed
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at ASC").Find(&feeds).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve calendar feeds",
+			"errors":  err.Error(),
+		})
+	}
+
+	items := make([]CalendarFeedItem, len(feeds))
+	for i := range feeds {
+		items[i] = calendarFeedItem(c, &feeds[i])
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Calendar feeds retrieved successfully",
+		"data":    items,
+	})
+}
+
+// RevokeCalendarFeed stops a calendar feed's URL from working
+func RevokeCalendarFeed(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var feed model.CalendarFeed
+	if err := db.Where("id = ? AND user_id = ?", c.Params("feed_id"), userID).First(&feed).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Calendar feed not found",
+		})
+	}
+
+	if feed.RevokedAt == nil {
+		now := time.Now()
+		feed.RevokedAt = &now
+		if err := db.Save(&feed).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't revoke calendar feed",
+				"errors":  err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Calendar feed revoked",
+		"data":    nil,
+	})
+}
+
+// GetCalendar serves a calendar feed as iCalendar. It needs no login; the
+// token in the URL is the secret. Goal deadlines and milestones are events,
+// habits are recurring all-day events on the first day of each period, and
+// due tasks are to-dos.
+func GetCalendar(c *fiber.Ctx) error {
+	notFound := func() error {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This calendar is invalid or has been revoked",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	var feed model.CalendarFeed
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+	if err := db.Where("token = ? AND revoked_at IS NULL", token).First(&feed).Error; err != nil {
+		return notFound()
+	}
+	var user model.User
+	if err := db.First(&user, feed.UserID).Error; err != nil {
+		return notFound()
+	}
+
+	var w ical.Writer
+	w.Begin("VCALENDAR")
+	w.Prop("VERSION", "2.0")
+	w.Prop("PRODID", "-//Accountability//Calendar Feed//EN")
+	w.Prop("CALSCALE", "GREGORIAN")
+	w.Prop("METHOD", "PUBLISH")
+	name := feed.Name
+	if name == "" {
+		name = "Accountability"
+	}
+	w.Prop("X-WR-CALNAME", ical.Text(name))
+	w.Prop("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	w.Prop("X-PUBLISHED-TTL", "PT1H")
+
+	if feed.Scope == model.CalendarAll || feed.Scope == model.CalendarGoals {
+		var goals []model.Goal
+		if err := db.Where("user_id = ? AND group_id IS NULL", user.ID).
+			Preload("Subgoals").Preload("Habits").
+			Order("created_at ASC").Find(&goals).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't retrieve goals",
+				"errors":  err.Error(),
+			})
+		}
+		loc := database.UserLocation(&user)
+		for i := range goals {
+			writeGoalEntries(&w, &goals[i], loc)
+		}
+	}
+
+	if feed.Scope != model.CalendarGoals {
+		query := db.Where("due_date IS NOT NULL")
+		if feed.Scope == model.CalendarList {
+			var list model.TaskList
+			if feed.ListID == nil || db.First(&list, *feed.ListID).Error != nil || listRole(db, &list, user.ID) == "" {
+				return notFound()
+			}
+			query = query.Where("task_list_id = ?", list.ID)
+		} else {
+			query = query.Where("task_list_id IN (?)", db.Model(&model.TaskList{}).Select("id").
+				Where("user_id = ? OR id IN (?)", user.ID, db.Model(&model.ListMember{}).Select("task_list_id").Where("user_id = ?", user.ID)))
+		}
+		var tasks []model.Task
+		if err := query.Order("due_date ASC").Find(&tasks).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't retrieve tasks",
+				"errors":  err.Error(),
+			})
+		}
+		for i := range tasks {
+			writeTaskEntry(&w, &tasks[i])
+		}
+	}
+
+	w.End("VCALENDAR")
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="calendar.ics"`)
+	return c.SendString(w.String())
+}
+
+// calendarUID returns the stable UID of a calendar entry
+func calendarUID(kind string, id uint) string {
+	return kind + "-" + strconv.FormatUint(uint64(id), 10) + calendarUIDDomain
+}
+
+// writeGoalEntries writes a goal's deadline, its milestones and, while the
+// goal is open, its habits
+func writeGoalEntries(w *ical.Writer, goal *model.Goal, loc *time.Location) {
+	done := func(summary string, completed bool) string {
+		if completed {
+			return "✓ " + summary
+		}
+		return summary
+	}
+
+	if !goal.Deadline.IsZero() {
+		w.Begin("VEVENT")
+		w.Prop("UID", calendarUID("goal", goal.ID))
+		w.Prop("DTSTAMP", ical.DateTime(goal.UpdatedAt))
+		w.Prop("LAST-MODIFIED", ical.DateTime(goal.UpdatedAt))
+		w.Prop("DTSTART", ical.DateTime(goal.Deadline))
+		w.Prop("SUMMARY", ical.Text(done("Deadline: "+goal.Name, goal.Completed)))
+		if goal.Description != "" {
+			w.Prop("DESCRIPTION", ical.Text(goal.Description))
+		}
+		w.Prop("CATEGORIES", "Goal")
+		w.End("VEVENT")
+	}
+
+	for _, subgoal := range goal.Subgoals {
+		if subgoal.Deadline == nil {
+			continue
+		}
+		w.Begin("VEVENT")
+		w.Prop("UID", calendarUID("subgoal", subgoal.ID))
+		w.Prop("DTSTAMP", ical.DateTime(subgoal.UpdatedAt))
+		w.Prop("LAST-MODIFIED", ical.DateTime(subgoal.UpdatedAt))
+		w.Prop("DTSTART", ical.DateTime(*subgoal.Deadline))
+		w.Prop("SUMMARY", ical.Text(done("Milestone: "+subgoal.Name+" ("+goal.Name+")", subgoal.Completed)))
+		w.Prop("CATEGORIES", "Milestone")
+		w.End("VEVENT")
+	}
+
+	if goal.Completed {
+		return
+	}
+	for _, habit := range goal.Habits {
+		freq := "DAILY"
+		switch habit.Frequency {
+		case "weekly":
+			freq = "WEEKLY"
+		case "monthly":
+			freq = "MONTHLY"
+		}
+		created := habit.CreatedAt.In(loc)
+		start := model.HabitPeriod(habit.Frequency, created)
+		rule := "FREQ=" + freq
+		if !goal.Deadline.IsZero() {
+			rule += ";UNTIL=" + ical.Date(goal.Deadline.In(loc))
+		}
+
+		w.Begin("VEVENT")
+		w.Prop("UID", calendarUID("habit", habit.ID))
+		w.Prop("DTSTAMP", ical.DateTime(habit.UpdatedAt))
+		w.Prop("LAST-MODIFIED", ical.DateTime(habit.UpdatedAt))
+		w.Prop("DTSTART;VALUE=DATE", ical.Date(start))
+		w.Prop("DTEND;VALUE=DATE", ical.Date(start.AddDate(0, 0, 1)))
+		w.Prop("RRULE", rule)
+		w.Prop("SUMMARY", ical.Text(habit.Name+" ("+goal.Name+")"))
+		w.Prop("TRANSP", "TRANSPARENT")
+		w.Prop("CATEGORIES", "Habit")
+		w.End("VEVENT")
+	}
+}
+
+// writeTaskEntry writes a due task as a to-do
+func writeTaskEntry(w *ical.Writer, task *model.Task) {
+	w.Begin("VTODO")
+	w.Prop("UID", calendarUID("task", task.ID))
+	w.Prop("DTSTAMP", ical.DateTime(task.UpdatedAt))
+	w.Prop("LAST-MODIFIED", ical.DateTime(task.UpdatedAt))
+	w.Prop("DUE", ical.DateTime(*task.DueDate))
+	w.Prop("SUMMARY", ical.Text(task.Text))
+	if task.Completed {
+		w.Prop("STATUS", "COMPLETED")
+		if task.CompletedAt != nil {
+			w.Prop("COMPLETED", ical.DateTime(*task.CompletedAt))
+		}
+	} else {
+		w.Prop("STATUS", "NEEDS-ACTION")
+	}
+	w.End("VTODO")
+}
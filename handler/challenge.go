@@ -0,0 +1,547 @@
+package handler
+
+import (
+	"crypto/rand"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// joinCodeAlphabet leaves out characters that are easy to confuse
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// joinCodeAttempts is how many codes are tried before giving up
+	joinCodeAttempts = 5
+)
+
+// newJoinCode returns a random 8 character join code
+func newJoinCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = joinCodeAlphabet[int(b[i])%len(joinCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// CreateChallenge creates a time-boxed challenge others can join by code
+func CreateChallenge(c *fiber.Ctx) error {
+	type CreateChallengeInput struct {
+		Name        string  `json:"name" validate:"required,min=1,max=255"`
+		Description string  `json:"description"`
+		StartDate   string  `json:"start_date" validate:"required,datetime=2006-01-02"`
+		EndDate     string  `json:"end_date" validate:"required,datetime=2006-01-02"`
+		Rule        string  `json:"rule" validate:"required,oneof=habit target"`
+		Frequency   string  `json:"frequency" validate:"omitempty,oneof=daily weekly monthly"`
+		Target      float64 `json:"target" validate:"required_if=Rule target,min=0"`
+		Unit        string  `json:"unit" validate:"max=50"`
+	}
+
+	var input CreateChallengeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	start, _ := time.Parse("2006-01-02", input.StartDate)
+	end, _ := time.Parse("2006-01-02", input.EndDate)
+	if end.Before(start) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The end date must not be before the start date",
+			"data":    nil,
+		})
+	}
+
+	// Targets are daily, so only habit challenges score in longer periods
+	frequency := input.Frequency
+	if frequency == "" {
+		frequency = "daily"
+	}
+	if input.Rule == model.ChallengeRuleTarget && frequency != "daily" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Target challenges can only be daily",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	challenge := model.Challenge{
+		Name:        input.Name,
+		Description: input.Description,
+		CreatorID:   userID,
+		StartDate:   start,
+		EndDate:     end,
+		Rule:        input.Rule,
+		Frequency:   frequency,
+		Target:      input.Target,
+		Unit:        input.Unit,
+	}
+	// Draw another code when one is already taken
+	for attempt := 0; ; attempt++ {
+		if attempt == joinCodeAttempts {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't generate a unique join code",
+				"data":    nil,
+			})
+		}
+		code, err := newJoinCode()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't generate join code",
+				"errors":  err.Error(),
+			})
+		}
+		challenge.JoinCode = code
+		res := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&challenge)
+		if res.Error != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't create challenge",
+				"errors":  res.Error.Error(),
+			})
+		}
+		if res.RowsAffected > 0 {
+			break
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Challenge created successfully",
+		"data":    challenge,
+	})
+}
+
+// GetChallenges lists the challenges the user created or joined
+func GetChallenges(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var challenges []model.Challenge
+	if err := db.Where("creator_id = ? OR id IN (?)", userID,
+		db.Model(&model.ChallengeParticipant{}).Select("challenge_id").Where("user_id = ?", userID)).
+		Order("start_date DESC").Find(&challenges).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve challenges",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Challenges retrieved successfully",
+		"data":    challenges,
+	})
+}
+
+// JoinChallenge enrols the user in the challenge with the given join code.
+// Habit challenges score from one of the user's habits, which must have the
+// challenge's frequency; target challenges
+// from progress recorded on one of their goals, which is created for them
+// when none is given.
+func JoinChallenge(c *fiber.Ctx) error {
+	type JoinChallengeInput struct {
+		Code    string `json:"code" validate:"required"`
+		HabitID *uint  `json:"habit_id"`
+		GoalID  *uint  `json:"goal_id"`
+	}
+
+	var input JoinChallengeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var challenge model.Challenge
+	if err := db.Where("join_code = ?", strings.ToUpper(strings.TrimSpace(input.Code))).First(&challenge).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No challenge found with this code",
+			"data":    nil,
+		})
+	}
+
+	if time.Now().UTC().After(challenge.EndDate.AddDate(0, 0, 1)) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This challenge has already ended",
+			"data":    nil,
+		})
+	}
+
+	var existing int64
+	db.Model(&model.ChallengeParticipant{}).Where("challenge_id = ? AND user_id = ?", challenge.ID, userID).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You have already joined this challenge",
+			"data":    nil,
+		})
+	}
+
+	participant := model.ChallengeParticipant{ChallengeID: challenge.ID, UserID: userID}
+	errBadInput := errors.New("bad input")
+	message := ""
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		switch challenge.Rule {
+		case model.ChallengeRuleHabit:
+			if input.HabitID == nil {
+				message = "A habit is required to join this challenge"
+				return errBadInput
+			}
+			var habit model.Habit
+			var goal model.Goal
+			if tx.First(&habit, *input.HabitID).Error != nil || tx.First(&goal, habit.GoalID).Error != nil || goal.UserID != userID {
+				message = "Habit not found"
+				return errBadInput
+			}
+			if model.HabitFrequency(habit.Frequency) != challenge.Frequency {
+				message = "The habit must be " + challenge.Frequency + " to join this challenge"
+				return errBadInput
+			}
+			participant.HabitID = &habit.ID
+
+		case model.ChallengeRuleTarget:
+			if input.GoalID != nil {
+				var goal model.Goal
+				if tx.First(&goal, *input.GoalID).Error != nil || goal.UserID != userID || goal.GroupID != nil {
+					message = "Goal not found"
+					return errBadInput
+				}
+				participant.GoalID = &goal.ID
+			} else {
+				goal := model.Goal{
+					UserID:      userID,
+					Name:        challenge.Name,
+					Description: challenge.Description,
+					Deadline:    challenge.EndDate,
+					Target:      challenge.Target,
+				}
+				if err := tx.Create(&goal).Error; err != nil {
+					return err
+				}
+				participant.GoalID = &goal.ID
+			}
+		}
+		return tx.Create(&participant).Error
+	})
+	if err == errBadInput {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": message,
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't join challenge",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Joined challenge",
+		"data": fiber.Map{
+			"challenge":   challenge,
+			"participant": participant,
+		},
+	})
+}
+
+// LeaveChallenge withdraws the user from a challenge
+func LeaveChallenge(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	res := database.DB.Unscoped().Where("challenge_id = ? AND user_id = ?", c.Params("challenge_id"), userID).
+		Delete(&model.ChallengeParticipant{})
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't leave challenge",
+			"errors":  res.Error.Error(),
+		})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You haven't joined this challenge",
+			"data":    nil,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Left challenge",
+		"data":    nil,
+	})
+}
+
+// DailyScore is the points a participant scored on one day
+type DailyScore struct {
+	Date   time.Time `json:"date"`
+	Points int       `json:"points"`
+}
+
+// ChallengeStanding is a participant's position on a challenge leaderboard
+type ChallengeStanding struct {
+	Rank       int          `json:"rank"`
+	UserID     uint         `json:"user_id"`
+	Username   string       `json:"username"`
+	Points     int          `json:"points"`
+	LongestRun int          `json:"longest_run"` // Most consecutive scoring periods
+	ReachedAt  *time.Time   `json:"reached_at"`  // Day the final score was reached
+	JoinedAt   time.Time    `json:"joined_at"`
+	Scores     []DailyScore `json:"scores"`
+}
+
+// scoreParticipant returns the days on which a participant scored, in order.
+// Scores follow the challenge's periods, whatever the habit's frequency.
+func scoreParticipant(db *gorm.DB, challenge *model.Challenge, participant *model.ChallengeParticipant, now time.Time) ([]DailyScore, error) {
+	end := challenge.EndDate
+	if today := model.HabitPeriod("daily", now); today.Before(end) {
+		end = today
+	}
+	scores := []DailyScore{}
+
+	switch challenge.Rule {
+	case model.ChallengeRuleHabit:
+		if participant.HabitID == nil {
+			return scores, nil
+		}
+		var habit model.Habit
+		if err := db.Unscoped().First(&habit, *participant.HabitID).Error; err != nil {
+			return nil, err
+		}
+		var dates []time.Time
+		if err := db.Model(&model.HabitCheckIn{}).
+			Where("habit_id = ? AND date BETWEEN ? AND ?", habit.ID, challenge.StartDate, end).
+			Order("date ASC").Pluck("date", &dates).Error; err != nil {
+			return nil, err
+		}
+		// One point per period, scored on the first check-in in it
+		var last time.Time
+		for _, date := range dates {
+			period := model.HabitPeriod(challenge.Frequency, date)
+			if len(scores) > 0 && period.Equal(last) {
+				continue
+			}
+			last = period
+			scores = append(scores, DailyScore{Date: date.UTC(), Points: 1})
+		}
+		return scores, nil
+
+	case model.ChallengeRuleTarget:
+		if participant.GoalID == nil {
+			return scores, nil
+		}
+		type dayTotal struct {
+			Date  time.Time
+			Total float64
+		}
+		var totals []dayTotal
+		if err := db.Model(&model.ProgressEntry{}).
+			Select("date, SUM(amount) AS total").
+			Where("goal_id = ? AND user_id = ? AND date BETWEEN ? AND ?", *participant.GoalID, participant.UserID, challenge.StartDate, end).
+			Group("date").Order("date ASC").
+			Scan(&totals).Error; err != nil {
+			return nil, err
+		}
+		for _, day := range totals {
+			if day.Total >= challenge.Target {
+				scores = append(scores, DailyScore{Date: day.Date.UTC(), Points: 1})
+			}
+		}
+	}
+	return scores, nil
+}
+
+// GetChallengeLeaderboard ranks a challenge's participants. Ties on points
+// are broken by the longest run of consecutive scoring periods, then by who
+// reached their score first, then by who joined first.
+func GetChallengeLeaderboard(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var challenge model.Challenge
+	if err := db.First(&challenge, c.Params("challenge_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Challenge not found",
+			"data":    nil,
+		})
+	}
+
+	var participants []model.ChallengeParticipant
+	if err := db.Where("challenge_id = ?", challenge.ID).Find(&participants).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch participants",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Only the creator and participants can see the leaderboard
+	allowed := challenge.CreatorID == userID
+	for _, p := range participants {
+		allowed = allowed || p.UserID == userID
+	}
+	if !allowed {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Challenge not found",
+			"data":    nil,
+		})
+	}
+
+	now := time.Now().UTC()
+	standings := []ChallengeStanding{}
+	for i := range participants {
+		p := &participants[i]
+		var user model.User
+		if err := db.First(&user, p.UserID).Error; err != nil {
+			continue
+		}
+
+		scores, err := scoreParticipant(db, &challenge, p, now)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't compute scores",
+				"errors":  err.Error(),
+			})
+		}
+
+		standing := ChallengeStanding{
+			UserID:   user.ID,
+			Username: user.Username,
+			JoinedAt: p.CreatedAt,
+			Scores:   scores,
+		}
+		run := 0
+		var last time.Time
+		for _, score := range scores {
+			standing.Points += score.Points
+			period := model.HabitPeriod(challenge.Frequency, score.Date)
+			if run > 0 && period.Equal(model.NextHabitPeriod(challenge.Frequency, last)) {
+				run++
+			} else {
+				run = 1
+			}
+			last = period
+			if run > standing.LongestRun {
+				standing.LongestRun = run
+			}
+		}
+		if len(scores) > 0 {
+			reached := scores[len(scores)-1].Date
+			standing.ReachedAt = &reached
+		}
+		standings = append(standings, standing)
+	}
+
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.LongestRun != b.LongestRun {
+			return a.LongestRun > b.LongestRun
+		}
+		if a.ReachedAt != nil && b.ReachedAt != nil && !a.ReachedAt.Equal(*b.ReachedAt) {
+			return a.ReachedAt.Before(*b.ReachedAt)
+		}
+		if !a.JoinedAt.Equal(b.JoinedAt) {
+			return a.JoinedAt.Before(b.JoinedAt)
+		}
+		return a.UserID < b.UserID
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Leaderboard retrieved successfully",
+		"data": fiber.Map{
+			"challenge":   challenge,
+			"leaderboard": standings,
+		},
+	})
+}
@@ -0,0 +1,387 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxMissedPeriods caps how far back missed check-ins are reported per goal
+const maxMissedPeriods = 12
+
+// checkInPeriod returns the start of the check-in period containing t.
+// Periods are CheckInCadence days long, counted from the day the goal was
+// created.
+func checkInPeriod(goal *model.Goal, t time.Time) time.Time {
+	cadence := checkInCadence(goal)
+	created := goal.CreatedAt.UTC()
+	start := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(t.UTC().Sub(start).Hours() / 24)
+	if days < 0 {
+		return start
+	}
+	return start.AddDate(0, 0, days-days%cadence)
+}
+
+// checkInCadence returns the number of days between a goal's check-ins
+func checkInCadence(goal *model.Goal) int {
+	if goal.CheckInCadence <= 0 {
+		return 7
+	}
+	return goal.CheckInCadence
+}
+
+// SubmitCheckIn records the owner's check-in for the goal's current period.
+// Submitting again in the same period replaces the check-in and resets its
+// review.
+func SubmitCheckIn(c *fiber.Ctx) error {
+	type CheckInInput struct {
+		Progress   string `json:"progress" validate:"required"`
+		Blockers   string `json:"blockers"`
+		Confidence int    `json:"confidence" validate:"required,min=1,max=10"`
+	}
+
+	var input CheckInInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var goal model.Goal
+	if err := db.First(&goal, c.Params("goal_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+
+	if goal.UserID != userID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to check in on this goal",
+			"data":    nil,
+		})
+	}
+
+	period := checkInPeriod(&goal, time.Now())
+	var checkIn model.CheckIn
+	if err := db.Where(model.CheckIn{GoalID: goal.ID, PeriodStart: period}).
+		Attrs(model.CheckIn{UserID: userID}).
+		FirstOrInit(&checkIn).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't save check-in",
+			"errors":  err.Error(),
+		})
+	}
+
+	checkIn.Progress = input.Progress
+	checkIn.Blockers = input.Blockers
+	checkIn.Confidence = input.Confidence
+	checkIn.ReviewStatus = model.ReviewPending
+	checkIn.ReviewerID = nil
+	checkIn.ReviewComment = ""
+	checkIn.ReviewedAt = nil
+
+	if err := db.Save(&checkIn).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't save check-in",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Check-in submitted",
+		"data":    checkIn,
+	})
+}
+
+// GetCheckIns lists a goal's check-ins, newest first, for anyone who can
+// see the goal
+func GetCheckIns(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var goal model.Goal
+	if err := db.First(&goal, c.Params("goal_id")).Error; err != nil || !canViewGoal(db, &goal, userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+
+	var checkIns []model.CheckIn
+	if err := db.Where("goal_id = ?", goal.ID).Order("period_start DESC").Find(&checkIns).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch check-ins",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Check-ins retrieved successfully",
+		"data":    checkIns,
+	})
+}
+
+// ReviewCheckIn lets an accountability partner confirm or comment on a
+// check-in for a goal they can see
+func ReviewCheckIn(c *fiber.Ctx) error {
+	type ReviewInput struct {
+		Status  string `json:"status" validate:"required,oneof=confirmed commented"`
+		Comment string `json:"comment" validate:"required_if=Status commented"`
+	}
+
+	var input ReviewInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var checkIn model.CheckIn
+	if err := db.First(&checkIn, c.Params("checkin_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Check-in not found",
+		})
+	}
+
+	var goal model.Goal
+	if err := db.First(&goal, checkIn.GoalID).Error; err != nil || !canViewGoal(db, &goal, userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Check-in not found",
+		})
+	}
+
+	// Only partners review; owners can't sign off on themselves
+	if goal.UserID == userID || !arePartners(db, goal.UserID, userID) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only accountability partners can review check-ins",
+			"data":    nil,
+		})
+	}
+
+	now := time.Now()
+	checkIn.ReviewStatus = input.Status
+	checkIn.ReviewerID = &userID
+	checkIn.ReviewComment = input.Comment
+	checkIn.ReviewedAt = &now
+	if err := db.Save(&checkIn).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't review check-in",
+			"errors":  err.Error(),
+		})
+	}
+
+	notify(db, goal.UserID, model.NotificationCheckInReview, &userID, model.ResourceGoal, goal.ID, actorName(db, userID)+" reviewed your check-in on \""+goal.Name+"\"")
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Check-in reviewed",
+		"data":    checkIn,
+	})
+}
+
+// GetCheckInsToReview lists pending check-ins on partners' goals the user
+// can see
+func GetCheckInsToReview(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	partnerIDs := db.Model(&model.Partnership{}).
+		Select("CASE WHEN requester_id = ? THEN addressee_id ELSE requester_id END", userID).
+		Where("(requester_id = ? OR addressee_id = ?) AND status = ?", userID, userID, model.PartnershipAccepted)
+	goalIDs := db.Model(&model.Goal{}).Select("id").
+		Where("user_id IN (?) AND visibility IN ?", partnerIDs, []string{model.VisibilityPartners, model.VisibilityPublic})
+
+	var checkIns []model.CheckIn
+	if err := db.Where("goal_id IN (?) AND review_status = ?", goalIDs, model.ReviewPending).
+		Order("created_at ASC").Find(&checkIns).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch check-ins",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Check-ins retrieved successfully",
+		"data":    checkIns,
+	})
+}
+
+// GetMissedCheckIns lists past check-in periods without a check-in for the
+// user's open goals, or for another user's goals visible to the caller when
+// a user_id query parameter is given
+func GetMissedCheckIns(c *fiber.Ctx) error {
+	type MissedCheckIn struct {
+		GoalID      uint      `json:"goal_id"`
+		GoalName    string    `json:"goal_name"`
+		PeriodStart time.Time `json:"period_start"`
+		PeriodEnd   time.Time `json:"period_end"`
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	ownerID := userID
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid user ID format",
+				"data":    nil,
+			})
+		}
+		ownerID = uint(id)
+	}
+
+	db := database.DB
+	var goals []model.Goal
+	if err := db.Where("user_id = ? AND completed = ?", ownerID, false).Find(&goals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch goals",
+			"errors":  err.Error(),
+		})
+	}
+
+	missed := []MissedCheckIn{}
+	now := time.Now()
+	for i := range goals {
+		goal := &goals[i]
+		if !canViewGoal(db, goal, userID) {
+			continue
+		}
+		periods, err := missedCheckIns(db, goal, now)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't fetch check-ins",
+				"errors":  err.Error(),
+			})
+		}
+		for _, start := range periods {
+			missed = append(missed, MissedCheckIn{
+				GoalID:      goal.ID,
+				GoalName:    goal.Name,
+				PeriodStart: start,
+				PeriodEnd:   start.AddDate(0, 0, checkInCadence(goal)),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Missed check-ins retrieved successfully",
+		"data":    missed,
+	})
+}
+
+// missedCheckIns returns the start of each finished period, most recent
+// first, that has no check-in. Periods after the goal's deadline are not
+// expected to have one.
+func missedCheckIns(db *gorm.DB, goal *model.Goal, now time.Time) ([]time.Time, error) {
+	var done []time.Time
+	if err := db.Model(&model.CheckIn{}).Where("goal_id = ?", goal.ID).Pluck("period_start", &done).Error; err != nil {
+		return nil, err
+	}
+	checkedIn := make(map[time.Time]bool)
+	for _, start := range done {
+		checkedIn[start.UTC()] = true
+	}
+
+	first := checkInPeriod(goal, goal.CreatedAt)
+	end := now
+	if !goal.Deadline.IsZero() && goal.Deadline.Before(end) {
+		end = goal.Deadline
+	}
+
+	cadence := checkInCadence(goal)
+	var missed []time.Time
+	period := checkInPeriod(goal, end)
+	if period.Equal(checkInPeriod(goal, now)) {
+		// The current period is still open, start with the one before it
+		period = period.AddDate(0, 0, -cadence)
+	}
+	for ; !period.Before(first) && len(missed) < maxMissedPeriods; period = period.AddDate(0, 0, -cadence) {
+		if !checkedIn[period] {
+			missed = append(missed, period)
+		}
+	}
+	return missed, nil
+}
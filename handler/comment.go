@@ -0,0 +1,623 @@
+package handler
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"app/database"
+	"app/model"
+	"app/outbox"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// mentionPattern matches @username mentions in comment bodies
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.-]{3,50})`)
+
+// canAccessResource reports whether a user can see a goal, subgoal or task,
+// and therefore read and write its comments and reactions
+func canAccessResource(db *gorm.DB, resourceType string, resourceID, userID uint) bool {
+	switch resourceType {
+	case model.ResourceGoal:
+		var goal model.Goal
+		return db.First(&goal, resourceID).Error == nil && canViewGoal(db, &goal, userID)
+	case model.ResourceSubgoal:
+		var subgoal model.Subgoal
+		if db.First(&subgoal, resourceID).Error != nil {
+			return false
+		}
+		return canAccessResource(db, model.ResourceGoal, subgoal.GoalID, userID)
+	case model.ResourceTask:
+		var task model.Task
+		if db.First(&task, resourceID).Error != nil {
+			return false
+		}
+		var list model.TaskList
+		return db.First(&list, task.TaskListID).Error == nil && listRole(db, &list, userID) != ""
+	}
+	return false
+}
+
+// resourceOwner returns the user who owns a goal, subgoal or task's list,
+// or 0 if the resource doesn't exist
+func resourceOwner(db *gorm.DB, resourceType string, resourceID uint) uint {
+	switch resourceType {
+	case model.ResourceGoal:
+		var goal model.Goal
+		if db.First(&goal, resourceID).Error == nil {
+			return goal.UserID
+		}
+	case model.ResourceSubgoal:
+		var subgoal model.Subgoal
+		if db.First(&subgoal, resourceID).Error == nil {
+			return resourceOwner(db, model.ResourceGoal, subgoal.GoalID)
+		}
+	case model.ResourceTask:
+		var task model.Task
+		var list model.TaskList
+		if db.First(&task, resourceID).Error == nil && db.First(&list, task.TaskListID).Error == nil {
+			return list.UserID
+		}
+	}
+	return 0
+}
+
+// commentCounts returns the number of comments per resource ID
+func commentCounts(db *gorm.DB, resourceType string, ids []uint) map[uint]int {
+	type row struct {
+		ResourceID uint
+		Count      int
+	}
+	counts := make(map[uint]int)
+	if len(ids) == 0 {
+		return counts
+	}
+
+	var rows []row
+	db.Model(&model.Comment{}).
+		Select("resource_id, COUNT(*) AS count").
+		Where("resource_type = ? AND resource_id IN ?", resourceType, ids).
+		Group("resource_id").
+		Scan(&rows)
+	for _, r := range rows {
+		counts[r.ResourceID] = r.Count
+	}
+	return counts
+}
+
+// fillGoalCommentCounts sets the comment counts of goals, their subgoals and
+// their linked tasks
+func fillGoalCommentCounts(db *gorm.DB, goals []model.Goal) {
+	var goalIDs, subgoalIDs, taskIDs []uint
+	for _, goal := range goals {
+		goalIDs = append(goalIDs, goal.ID)
+		for _, subgoal := range goal.Subgoals {
+			subgoalIDs = append(subgoalIDs, subgoal.ID)
+		}
+		for _, task := range goal.Tasks {
+			taskIDs = append(taskIDs, task.ID)
+		}
+	}
+
+	goalCounts := commentCounts(db, model.ResourceGoal, goalIDs)
+	subgoalCounts := commentCounts(db, model.ResourceSubgoal, subgoalIDs)
+	taskCounts := commentCounts(db, model.ResourceTask, taskIDs)
+	for i := range goals {
+		goals[i].CommentCount = goalCounts[goals[i].ID]
+		for j := range goals[i].Subgoals {
+			goals[i].Subgoals[j].CommentCount = subgoalCounts[goals[i].Subgoals[j].ID]
+		}
+		for j := range goals[i].Tasks {
+			goals[i].Tasks[j].CommentCount = taskCounts[goals[i].Tasks[j].ID]
+		}
+	}
+}
+
+// fillListCommentCounts sets the comment counts of the tasks in lists
+func fillListCommentCounts(db *gorm.DB, lists []model.TaskList) {
+	var taskIDs []uint
+	for _, list := range lists {
+		for _, task := range list.Tasks {
+			taskIDs = append(taskIDs, task.ID)
+		}
+	}
+
+	counts := commentCounts(db, model.ResourceTask, taskIDs)
+	for i := range lists {
+		for j := range lists[i].Tasks {
+			lists[i].Tasks[j].CommentCount = counts[lists[i].Tasks[j].ID]
+		}
+	}
+}
+
+// resolveMentions looks up the users @mentioned in a comment body. Users who
+// can't see the resource aren't mentioned, so they never learn about it.
+func resolveMentions(db *gorm.DB, body, resourceType string, resourceID uint) []model.CommentMention {
+	var mentions []model.CommentMention
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// Punctuation ending a sentence isn't part of the username
+		username := strings.TrimRight(match[1], ".-")
+		if len(username) < 3 || seen[username] {
+			continue
+		}
+		seen[username] = true
+
+		user, err := getUserByUsername(username)
+		if err != nil || user == nil || !canAccessResource(db, resourceType, resourceID, user.ID) {
+			continue
+		}
+		mentions = append(mentions, model.CommentMention{UserID: user.ID, Username: user.Username})
+	}
+	return mentions
+}
+
+// parseResource reads the resource type and ID from the route
+func parseResource(c *fiber.Ctx) (string, uint, bool) {
+	resourceType := c.Params("type")
+	switch resourceType {
+	case model.ResourceGoal, model.ResourceSubgoal, model.ResourceTask:
+	default:
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return resourceType, uint(id), true
+}
+
+// GetComments returns the comment threads on a goal, subgoal or task
+func GetComments(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	resourceType, resourceID, ok := parseResource(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid resource",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	if !canAccessResource(db, resourceType, resourceID, userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Resource not found",
+			"data":    nil,
+		})
+	}
+
+	// Deleted comments are kept as placeholders while they still have replies
+	var comments []model.Comment
+	if err := db.Unscoped().Preload("Mentions").
+		Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+		Order("created_at ASC").Find(&comments).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch comments",
+			"errors":  err.Error(),
+		})
+	}
+
+	usernames := make(map[uint]string)
+	for i := range comments {
+		if _, found := usernames[comments[i].UserID]; !found {
+			var user model.User
+			db.Unscoped().Select("username").First(&user, comments[i].UserID)
+			usernames[comments[i].UserID] = user.Username
+		}
+		comments[i].Username = usernames[comments[i].UserID]
+		if comments[i].DeletedAt.Valid {
+			comments[i].Body = ""
+			comments[i].Mentions = nil
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Comments retrieved successfully",
+		"data":    threadComments(comments),
+	})
+}
+
+// threadComments nests replies under their parents, dropping deleted
+// comments that have no remaining replies
+func threadComments(comments []model.Comment) []model.Comment {
+	children := make(map[uint][]model.Comment)
+	var roots []model.Comment
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		} else {
+			children[*comment.ParentID] = append(children[*comment.ParentID], comment)
+		}
+	}
+
+	var build func(list []model.Comment) []model.Comment
+	build = func(list []model.Comment) []model.Comment {
+		threaded := []model.Comment{}
+		for _, comment := range list {
+			comment.Replies = build(children[comment.ID])
+			if comment.DeletedAt.Valid && len(comment.Replies) == 0 {
+				continue
+			}
+			threaded = append(threaded, comment)
+		}
+		return threaded
+	}
+	return build(roots)
+}
+
+// CreateComment comments on a goal, subgoal or task, optionally in reply to
+// another comment on it
+func CreateComment(c *fiber.Ctx) error {
+	type CommentInput struct {
+		Body     string `json:"body" validate:"required,min=1,max=5000"`
+		ParentID *uint  `json:"parent_id"`
+	}
+
+	var input CommentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	resourceType, resourceID, ok := parseResource(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid resource",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	if !canAccessResource(db, resourceType, resourceID, userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Resource not found",
+			"data":    nil,
+		})
+	}
+
+	if input.ParentID != nil {
+		var parent model.Comment
+		if err := db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+			First(&parent, *input.ParentID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Parent comment not found",
+				"data":    nil,
+			})
+		}
+	}
+
+	comment := model.Comment{
+		UserID:       userID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ParentID:     input.ParentID,
+		Body:         strings.TrimSpace(input.Body),
+		Mentions:     resolveMentions(db, input.Body, resourceType, resourceID),
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return outbox.Emit(tx, "comment.created", userID, resourceType, resourceID, comment)
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create comment",
+			"errors":  err.Error(),
+		})
+	}
+
+	author := actorName(db, userID)
+	mentioned := make(map[uint]bool)
+	for _, mention := range comment.Mentions {
+		mentioned[mention.UserID] = true
+		notify(db, mention.UserID, model.NotificationMention, &userID, resourceType, resourceID, author+" mentioned you in a comment")
+	}
+	if owner := resourceOwner(db, resourceType, resourceID); owner != 0 && !mentioned[owner] {
+		notify(db, owner, model.NotificationComment, &userID, resourceType, resourceID, author+" commented on your "+resourceType)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Comment created successfully",
+		"data":    comment,
+	})
+}
+
+// UpdateComment edits a comment; only its author may do so
+func UpdateComment(c *fiber.Ctx) error {
+	type CommentInput struct {
+		Body string `json:"body" validate:"required,min=1,max=5000"`
+	}
+
+	var input CommentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var comment model.Comment
+	if err := db.First(&comment, c.Params("comment_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Comment not found",
+			"data":    nil,
+		})
+	}
+
+	if comment.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to edit this comment",
+			"data":    nil,
+		})
+	}
+
+	comment.Body = strings.TrimSpace(input.Body)
+	comment.Mentions = resolveMentions(db, input.Body, comment.ResourceType, comment.ResourceID)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&model.CommentMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(&comment).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update comment",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Comment updated successfully",
+		"data":    comment,
+	})
+}
+
+// DeleteComment soft-deletes a comment; only its author may do so
+func DeleteComment(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var comment model.Comment
+	if err := db.First(&comment, c.Params("comment_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Comment not found",
+			"data":    nil,
+		})
+	}
+
+	if comment.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to delete this comment",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Delete(&comment).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't delete comment",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Comment deleted successfully",
+		"data":    nil,
+	})
+}
+
+// GetReactions summarizes the reactions on a goal, subgoal or task
+func GetReactions(c *fiber.Ctx) error {
+	type ReactionSummary struct {
+		Emoji   string `json:"emoji"`
+		Count   int    `json:"count"`
+		Reacted bool   `json:"reacted"` // Whether the caller reacted with this emoji
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	resourceType, resourceID, ok := parseResource(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid resource",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	if !canAccessResource(db, resourceType, resourceID, userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Resource not found",
+			"data":    nil,
+		})
+	}
+
+	var summary []ReactionSummary
+	if err := db.Model(&model.Reaction{}).
+		Select("emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted", userID).
+		Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+		Group("emoji").Order("MIN(created_at) ASC").
+		Scan(&summary).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch reactions",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Reactions retrieved successfully",
+		"data":    summary,
+	})
+}
+
+// AddReaction reacts to a goal, subgoal or task with an emoji
+func AddReaction(c *fiber.Ctx) error {
+	return reactionAction(c, true)
+}
+
+// RemoveReaction takes back a reaction
+func RemoveReaction(c *fiber.Ctx) error {
+	return reactionAction(c, false)
+}
+
+func reactionAction(c *fiber.Ctx, add bool) error {
+	type ReactionInput struct {
+		Emoji string `json:"emoji" validate:"required,max=32,excludesall= "`
+	}
+
+	var input ReactionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	resourceType, resourceID, ok := parseResource(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid resource",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	if !canAccessResource(db, resourceType, resourceID, userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Resource not found",
+			"data":    nil,
+		})
+	}
+
+	reaction := model.Reaction{
+		UserID:       userID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Emoji:        input.Emoji,
+	}
+
+	var err error
+	if add {
+		err = db.Where(reaction).FirstOrCreate(&reaction).Error
+	} else {
+		err = db.Unscoped().Where(reaction).Delete(&model.Reaction{}).Error
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update reaction",
+			"errors":  err.Error(),
+		})
+	}
+
+	message := "Reaction added"
+	if !add {
+		message = "Reaction removed"
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    nil,
+	})
+}
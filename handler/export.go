@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"app/archive"
+	"app/database"
+	"app/jobs"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// JobBuildExport builds a data export archive in the background
+const JobBuildExport = "export.build"
+
+// BuildExportPayload is the payload of JobBuildExport
+type BuildExportPayload struct {
+	ExportID uint `json:"export_id"`
+}
+
+// exportTTL is how long the download link of a data export works
+const exportTTL = 7 * 24 * time.Hour
+
+// DataExportItem is a data export with its download URL, once it's ready
+type DataExportItem struct {
+	model.DataExport
+	URL string `json:"url,omitempty"`
+}
+
+func dataExportItem(c *fiber.Ctx, export *model.DataExport) DataExportItem {
+	item := DataExportItem{DataExport: *export}
+	if export.Status == model.ExportSucceeded && export.ExpiresAt != nil && export.ExpiresAt.After(time.Now()) {
+		item.URL = c.BaseURL() + "/api/public/export/" + export.Token
+	}
+	return item
+}
+
+// StartExport starts building an archive of all of the user's data: the
+// profile, lists, tasks, goals, subgoals, habits and logs, in JSON and CSV.
+// The user is notified when the download link is ready.
+func StartExport(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var export model.DataExport
+	if err := db.Omit("archive").Where("user_id = ? AND status IN ?", userID, []string{model.ExportQueued, model.ExportRunning}).
+		Limit(1).Find(&export).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't start export",
+			"errors":  err.Error(),
+		})
+	}
+	if export.ID != 0 {
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "Your export is already being built",
+			"data":    dataExportItem(c, &export),
+		})
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't generate download token",
+			"errors":  err.Error(),
+		})
+	}
+	export = model.DataExport{UserID: userID, Status: model.ExportQueued, Token: token}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&export).Error; err != nil {
+			return err
+		}
+		_, err := jobs.Enqueue(tx, JobBuildExport, BuildExportPayload{ExportID: export.ID},
+			jobs.Options{UniqueKey: "export:" + strconv.FormatUint(uint64(export.ID), 10)})
+		return err
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't start export",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Export started",
+		"data":    dataExportItem(c, &export),
+	})
+}
+
+// GetExports lists the user's data exports, newest first
+func GetExports(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var exports []model.DataExport
+	if err := database.DB.Omit("archive").Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").Find(&exports).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve exports",
+			"errors":  err.Error(),
+		})
+	}
+
+	items := make([]DataExportItem, len(exports))
+	for i := range exports {
+		items[i] = dataExportItem(c, &exports[i])
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Exports retrieved successfully",
+		"data":    items,
+	})
+}
+
+// DownloadExport serves a data export archive. It needs no login; the token
+// in the link is the secret, and it stops working when the export expires.
+func DownloadExport(c *fiber.Ctx) error {
+	var export model.DataExport
+	if err := database.DB.Where("token = ? AND status = ? AND expires_at > ?", c.Params("token"), model.ExportSucceeded, time.Now()).
+		First(&export).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This download link is invalid or has expired",
+			"data":    nil,
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Attachment("accountability-export-" + export.CreatedAt.Format("2006-01-02") + ".zip")
+	return c.Send(export.Archive)
+}
+
+// RestoreAccount restores an archive from StartExport, uploaded as the
+// multipart field "file", into the user's account. The account must not
+// have any lists, goals or labels yet.
+func RestoreAccount(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Upload the export archive",
+			"data":    nil,
+		})
+	}
+	f, err := header.Open()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't read the uploaded file",
+			"errors":  err.Error(),
+		})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't read the uploaded file",
+			"errors":  err.Error(),
+		})
+	}
+
+	var counts map[string]int
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		counts, err = archive.Restore(tx, userID, data)
+		return err
+	})
+	switch {
+	case errors.Is(err, archive.ErrNotFresh):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	case errors.Is(err, archive.ErrInvalidArchive):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't read the archive",
+			"errors":  err.Error(),
+		})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't restore the archive",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account restored successfully",
+		"data":    counts,
+	})
+}
+
+// buildExport builds the archive of a data export and notifies the user
+func buildExport(ctx context.Context, payload BuildExportPayload) error {
+	db := database.DB
+	var export model.DataExport
+	if err := db.Omit("archive").First(&export, payload.ExportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if export.Status == model.ExportSucceeded {
+		return nil
+	}
+	if err := db.Model(&export).Update("status", model.ExportRunning).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data, err := archive.Build(db.WithContext(ctx), export.UserID, now)
+	if err != nil {
+		// Let the job runner retry; the export shows as failed meanwhile and
+		// exportFailed finishes it once the attempts run out
+		if updateErr := db.Model(&export).Updates(map[string]interface{}{
+			"status": model.ExportFailed,
+			"error":  err.Error(),
+		}).Error; updateErr != nil {
+			log.Println("Error updating export: ", updateErr.Error())
+		}
+		return err
+	}
+
+	expiresAt := now.Add(exportTTL)
+	if err := db.Model(&export).Updates(map[string]interface{}{
+		"status":      model.ExportSucceeded,
+		"archive":     data,
+		"size":        len(data),
+		"error":       "",
+		"finished_at": now,
+		"expires_at":  expiresAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	notify(db, export.UserID, model.NotificationExport, nil, model.ResourceExport, export.ID,
+		"Your data export is ready to download until "+expiresAt.UTC().Format("Jan 2, 15:04 UTC"))
+	return nil
+}
+
+// exportFailed finishes an export whose job has run out of attempts and
+// tells the user
+func exportFailed(payload BuildExportPayload, err error) {
+	db := database.DB
+	var export model.DataExport
+	if db.Omit("archive").First(&export, payload.ExportID).Error != nil || export.Status == model.ExportSucceeded {
+		return
+	}
+	if updateErr := db.Model(&export).Updates(map[string]interface{}{
+		"status":      model.ExportFailed,
+		"error":       err.Error(),
+		"finished_at": time.Now(),
+	}).Error; updateErr != nil {
+		log.Println("Error updating export: ", updateErr.Error())
+		return
+	}
+	notify(db, export.UserID, model.NotificationExport, nil, model.ResourceExport, export.ID,
+		"Your data export failed: "+err.Error())
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 func CreateGoal(c *fiber.Ctx) error {
@@ -15,8 +16,9 @@ func CreateGoal(c *fiber.Ctx) error {
 		Frequency string `json:"frequency"`
 	}
 	type SubgoalInput struct {
-		Name      string `json:"name"`
-		Completed bool   `json:"completed"`
+		Name      string     `json:"name"`
+		Completed bool       `json:"completed"`
+		Deadline  *time.Time `json:"deadline"`
 	}
 	type CreateGoalInput struct {
 		Name        string         `json:"name" validate:"required,min=1"`
@@ -26,6 +28,9 @@ func CreateGoal(c *fiber.Ctx) error {
 		HowMuch     string         `json:"how_much"`
 		Resources   string         `json:"resources"`
 		Alignment   string         `json:"alignment"`
+		Visibility  string         `json:"visibility" validate:"omitempty,oneof=private partners public"`
+		Cadence     int            `json:"check_in_cadence" validate:"omitempty,min=1,max=365"` // Days between check-ins
+		CountTasks  bool           `json:"count_tasks"`
 		Subgoals    []SubgoalInput `json:"subgoals"` // List of subgoal names
 		Habits      []HabitInput   `json:"habits"`   // List of habits with names and frequency
 	}
@@ -68,14 +73,20 @@ func CreateGoal(c *fiber.Ctx) error {
 		HowMuch:     input.HowMuch,
 		Resources:   input.Resources,
 		Alignment:   input.Alignment,
+		Visibility:  input.Visibility,
+		CountTasks:  input.CountTasks,
 		Completed:   false,
 	}
+	if input.Cadence != 0 {
+		goal.CheckInCadence = input.Cadence
+	}
 
 	// Add subgoals
 	for _, subgoal := range input.Subgoals {
 		goal.Subgoals = append(goal.Subgoals, model.Subgoal{
 			Name:      subgoal.Name,
 			Completed: subgoal.Completed,
+			Deadline:  subgoal.Deadline,
 		})
 	}
 
@@ -88,7 +99,12 @@ func CreateGoal(c *fiber.Ctx) error {
 	}
 
 	// Save to DB
-	if err := db.Create(&goal).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&goal).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceGoal, goal.ID, &goal.ID, model.ActivityCreated, goal.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't create goal",
@@ -114,7 +130,9 @@ func GetGoals(c *fiber.Ctx) error {
 
 	var goals []model.Goal
 	db := database.DB
-	if err := db.Where("user_id = ?", uint(userID)).Preload("Subgoals").Preload("Habits").Find(&goals).Error; err != nil {
+	if err := db.Where("user_id = ? AND group_id IS NULL", uint(userID)).Preload("Subgoals").Preload("Habits").Preload("Tasks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Find(&goals).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't fetch goals",
@@ -122,6 +140,18 @@ func GetGoals(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := database.FillHabitStreaks(db, goals); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch habit streaks",
+			"errors":  err.Error(),
+		})
+	}
+	for i := range goals {
+		goals[i].Progress = model.GoalProgress(&goals[i])
+	}
+	fillGoalCommentCounts(db, goals)
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Goals retrieved successfully",
@@ -167,8 +197,13 @@ func DeleteGoal(c *fiber.Ctx) error {
 		})
 	}
 
-	// Delete the goal
-	if err := db.Delete(&goal).Error; err != nil {
+	// Delete the goal together with its subgoals and habits
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := database.DeleteGoal(tx, &goal, time.Now()); err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceGoal, goal.ID, &goal.ID, model.ActivityDeleted, goal.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't delete goal",
@@ -211,16 +246,19 @@ func UpdateGoal(c *fiber.Ctx) error {
 		})
 	}
 
+	// Entries carrying the ID of an existing subgoal or habit are updated in
+	// place so that anything referencing them (e.g. linked tasks) survives
 	type HabitInput struct {
+		ID        uint   `json:"id"`
 		Name      string `json:"name"`
 		Frequency string `json:"frequency"`
 	}
 
-	//TODO: Add deadline to subgoals
-
 	type SubgoalInput struct {
-		Name      string `json:"name"`
-		Completed bool   `json:"completed"`
+		ID        uint       `json:"id"`
+		Name      string     `json:"name"`
+		Completed bool       `json:"completed"`
+		Deadline  *time.Time `json:"deadline"`
 	}
 
 	type UpdateGoalInput struct {
@@ -231,6 +269,9 @@ func UpdateGoal(c *fiber.Ctx) error {
 		HowMuch     string         `json:"how_much"`
 		Resources   string         `json:"resources"`
 		Alignment   string         `json:"alignment"`
+		Visibility  string         `json:"visibility" validate:"omitempty,oneof=private partners public"`
+		Cadence     int            `json:"check_in_cadence" validate:"omitempty,min=1,max=365"` // Days between check-ins
+		CountTasks  bool           `json:"count_tasks"`
 		Subgoals    []SubgoalInput `json:"subgoals"` // List of subgoal names
 		Habits      []HabitInput   `json:"habits"`   // List of habits with names and frequency
 	}
@@ -244,6 +285,15 @@ func UpdateGoal(c *fiber.Ctx) error {
 		})
 	}
 
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
 	goal.Name = input.Name
 	goal.Deadline = input.Deadline
 	goal.Description = input.Description
@@ -251,31 +301,86 @@ func UpdateGoal(c *fiber.Ctx) error {
 	goal.HowMuch = input.HowMuch
 	goal.Resources = input.Resources
 	goal.Alignment = input.Alignment
+	goal.CountTasks = input.CountTasks
+	if input.Visibility != "" {
+		goal.Visibility = input.Visibility
+	}
+	if input.Cadence != 0 {
+		goal.CheckInCadence = input.Cadence
+	}
 
-	// Clear existing subgoals and add new ones
-	db.Where("goal_id = ?", goal.ID).Delete(&model.Subgoal{})
+	// Keep subgoals that are still referenced, add new ones and drop the rest
+	existingSubgoals := make(map[uint]model.Subgoal)
+	for _, subgoal := range goal.Subgoals {
+		existingSubgoals[subgoal.ID] = subgoal
+	}
 	goal.Subgoals = []model.Subgoal{} // Reset the subgoals slice
-	for _, subgoal := range input.Subgoals {
-		goal.Subgoals = append(goal.Subgoals, model.Subgoal{
-			GoalID:    goal.ID,
-			Name:      subgoal.Name,
-			Completed: subgoal.Completed,
-		})
+	for _, in := range input.Subgoals {
+		subgoal, found := existingSubgoals[in.ID]
+		if !found {
+			subgoal = model.Subgoal{GoalID: goal.ID}
+		}
+		delete(existingSubgoals, in.ID)
+		subgoal.Name = in.Name
+		subgoal.Completed = in.Completed
+		subgoal.Deadline = in.Deadline
+		goal.Subgoals = append(goal.Subgoals, subgoal)
+	}
+	removedSubgoals := make([]uint, 0, len(existingSubgoals))
+	for id := range existingSubgoals {
+		removedSubgoals = append(removedSubgoals, id)
+	}
+
+	// Same for habits
+	existingHabits := make(map[uint]model.Habit)
+	for _, habit := range goal.Habits {
+		existingHabits[habit.ID] = habit
 	}
-
-	// Clear existing habits and add new ones
-	db.Where("goal_id = ?", goal.ID).Delete(&model.Habit{})
 	goal.Habits = []model.Habit{} // Reset the habits slice
-	for _, habit := range input.Habits {
-		goal.Habits = append(goal.Habits, model.Habit{
-			GoalID:    goal.ID,
-			Name:      habit.Name,
-			Frequency: habit.Frequency,
-		})
+	for _, in := range input.Habits {
+		habit, found := existingHabits[in.ID]
+		if !found {
+			habit = model.Habit{GoalID: goal.ID}
+		}
+		delete(existingHabits, in.ID)
+		habit.Name = in.Name
+		habit.Frequency = in.Frequency
+		goal.Habits = append(goal.Habits, habit)
+	}
+	removedHabits := make([]uint, 0, len(existingHabits))
+	for id := range existingHabits {
+		removedHabits = append(removedHabits, id)
 	}
 
 	// Save changes
-	if err := db.Save(&goal).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if len(removedSubgoals) > 0 {
+			// Tasks linked to a removed subgoal stay linked to the goal itself
+			if err := tx.Model(&model.Task{}).Where("subgoal_id IN ?", removedSubgoals).
+				Update("subgoal_id", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.Subgoal{}, removedSubgoals).Error; err != nil {
+				return err
+			}
+		}
+		if len(removedHabits) > 0 {
+			if err := tx.Unscoped().Where("habit_id IN ?", removedHabits).Delete(&model.HabitCheckIn{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.ChallengeParticipant{}).Where("habit_id IN ?", removedHabits).
+				Update("habit_id", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.Habit{}, removedHabits).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&goal).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceGoal, goal.ID, &goal.ID, model.ActivityUpdated, goal.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't update goal",
@@ -293,6 +398,14 @@ func UpdateGoal(c *fiber.Ctx) error {
 func ToggleGoalCompletedStatus(c *fiber.Ctx) error {
 	id := c.Params("goal_id")
 
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
 	var goal model.Goal
 	db := database.DB
 	if err := db.First(&goal, id).Error; err != nil {
@@ -302,8 +415,25 @@ func ToggleGoalCompletedStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	// Check if the goal belongs to the user
+	if goal.UserID != userID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to modify this goal",
+		})
+	}
+
 	goal.Completed = !goal.Completed
-	if err := db.Save(&goal).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&goal).Error; err != nil {
+			return err
+		}
+		if err := updateStakeClaim(tx, &goal); err != nil {
+			return err
+		}
+		return recordActivity(tx, goal.UserID, model.ResourceGoal, goal.ID, &goal.ID, completionAction(goal.Completed), goal.Name)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't toggle goal completed status",
@@ -368,7 +498,12 @@ func ToggleSubgoalCompletedStatus(c *fiber.Ctx) error {
 	subgoal.Completed = !subgoal.Completed
 
 	// Save the updated subgoal
-	if err := db.Save(&subgoal).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&subgoal).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceSubgoal, subgoal.ID, &goal.ID, completionAction(subgoal.Completed), subgoal.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't update subgoal status",
@@ -382,3 +517,81 @@ func ToggleSubgoalCompletedStatus(c *fiber.Ctx) error {
 		"data":    subgoal,
 	})
 }
+
+func AddGoalProgress(c *fiber.Ctx) error {
+	type ProgressInput struct {
+		Amount float64 `json:"amount" validate:"required,gt=0"`
+		Note   string  `json:"note"`
+		Date   string  `json:"date" validate:"omitempty,datetime=2006-01-02"` // Defaults to today
+	}
+
+	var input ProgressInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var goal model.Goal
+	if err := db.First(&goal, c.Params("goal_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+
+	// Check if the goal belongs to the user
+	if goal.UserID != uint(userID) || goal.GroupID != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to record progress on this goal",
+			"data":    nil,
+		})
+	}
+
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if input.Date != "" {
+		date, _ = time.Parse("2006-01-02", input.Date)
+	}
+
+	entry := model.ProgressEntry{
+		GoalID: goal.ID,
+		UserID: userID,
+		Amount: input.Amount,
+		Note:   input.Note,
+		Date:   date,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't record progress",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Progress recorded",
+		"data":    entry,
+	})
+}
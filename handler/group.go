@@ -0,0 +1,960 @@
+package handler
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// groupFeedSize is the number of entries returned by a group activity feed
+const groupFeedSize = 50
+
+// groupRole returns the user's role in a group, or "" if they aren't a member
+func groupRole(db *gorm.DB, groupID, userID uint) string {
+	var member model.GroupMember
+	if err := db.Where("group_id = ? AND user_id = ?", groupID, userID).Limit(1).Find(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// loadGroup resolves the group addressed by the route and checks that the
+// user is a member (or an admin, when adminOnly is set). On failure the
+// error response has already been written and is returned.
+func loadGroup(c *fiber.Ctx, adminOnly bool) (*model.Group, uint, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return nil, 0, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var group model.Group
+	if err := db.First(&group, "id = ?", c.Params("group_id")).Error; err != nil {
+		return nil, 0, c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+			"data":    nil,
+		})
+	}
+
+	role := groupRole(db, group.ID, userID)
+	if role == "" {
+		return nil, 0, c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+			"data":    nil,
+		})
+	}
+	if adminOnly && role != model.GroupRoleAdmin {
+		return nil, 0, c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only group admins can do this",
+			"data":    nil,
+		})
+	}
+	return &group, userID, nil
+}
+
+// CreateGroup creates a group with the caller as its first admin
+func CreateGroup(c *fiber.Ctx) error {
+	type CreateGroupInput struct {
+		Name        string `json:"name" validate:"required,min=1,max=255"`
+		Description string `json:"description"`
+	}
+
+	var input CreateGroupInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	group := model.Group{
+		Name:        input.Name,
+		Description: input.Description,
+		OwnerID:     userID,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.GroupMember{GroupID: group.ID, UserID: userID, Role: model.GroupRoleAdmin}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create group",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group created successfully",
+		"data":    group,
+	})
+}
+
+// GetGroups lists the groups the user belongs to
+func GetGroups(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var groups []model.Group
+	if err := db.Where("id IN (?)", db.Model(&model.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Order("created_at ASC").Find(&groups).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve groups",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Groups retrieved successfully",
+		"data":    groups,
+	})
+}
+
+// GetGroup returns a group with its members
+func GetGroup(c *fiber.Ctx) error {
+	type MemberOutput struct {
+		UserID   uint      `json:"user_id"`
+		Username string    `json:"username"`
+		Name     string    `json:"name"`
+		Role     string    `json:"role"`
+		JoinedAt time.Time `json:"joined_at"`
+	}
+
+	group, _, err := loadGroup(c, false)
+	if group == nil {
+		return err
+	}
+
+	var members []MemberOutput
+	if err := database.DB.Model(&model.GroupMember{}).
+		Select("users.id AS user_id, users.username, users.name, group_members.role, group_members.created_at AS joined_at").
+		Joins("JOIN users ON users.id = group_members.user_id AND users.deleted_at IS NULL").
+		Where("group_members.group_id = ?", group.ID).
+		Order("group_members.created_at ASC").
+		Scan(&members).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve members",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group retrieved successfully",
+		"data": fiber.Map{
+			"group":   group,
+			"members": members,
+		},
+	})
+}
+
+// UpdateGroup renames a group or changes its description
+func UpdateGroup(c *fiber.Ctx) error {
+	type UpdateGroupInput struct {
+		Name        string `json:"name" validate:"required,min=1,max=255"`
+		Description string `json:"description"`
+	}
+
+	var input UpdateGroupInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	group, _, err := loadGroup(c, true)
+	if group == nil {
+		return err
+	}
+
+	group.Name = input.Name
+	group.Description = input.Description
+	if err := database.DB.Save(group).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update group",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group updated successfully",
+		"data":    group,
+	})
+}
+
+// DeleteGroup deletes a group along with its group goals
+func DeleteGroup(c *fiber.Ctx) error {
+	group, _, err := loadGroup(c, true)
+	if group == nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var goals []model.Goal
+		if err := tx.Where("group_id = ?", group.ID).Find(&goals).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for i := range goals {
+			if err := database.DeleteGoal(tx, &goals[i], now); err != nil {
+				return err
+			}
+		}
+		return tx.Model(group).Update("deleted_at", now).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't delete group",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group deleted successfully",
+		"data":    nil,
+	})
+}
+
+// AddGroupMember invites a user to a group by username. They join once
+// they accept the invitation.
+func AddGroupMember(c *fiber.Ctx) error {
+	type AddMemberInput struct {
+		Username string `json:"username" validate:"required"`
+		Role     string `json:"role" validate:"omitempty,oneof=admin member"`
+	}
+
+	var input AddMemberInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	group, userID, err := loadGroup(c, true)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	user, err := getUserByUsername(input.Username)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+			"errors":  err.Error(),
+		})
+	}
+	// Users who blocked each other look the same as unknown ones
+	if user == nil || isBlocked(db, userID, user.ID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+			"data":    nil,
+		})
+	}
+
+	if groupRole(db, group.ID, user.ID) != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User is already a member of this group",
+			"data":    nil,
+		})
+	}
+
+	var pending int64
+	db.Model(&model.GroupInvitation{}).
+		Where("group_id = ? AND invitee_id = ? AND status = ?", group.ID, user.ID, model.InvitationPending).
+		Count(&pending)
+	if pending > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User has already been invited to this group",
+			"data":    nil,
+		})
+	}
+
+	invitation := model.GroupInvitation{
+		GroupID:   group.ID,
+		InviterID: userID,
+		InviteeID: user.ID,
+		Role:      input.Role,
+		Status:    model.InvitationPending,
+	}
+	if invitation.Role == "" {
+		invitation.Role = model.GroupRoleMember
+	}
+	if err := db.Create(&invitation).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create invitation",
+			"errors":  err.Error(),
+		})
+	}
+
+	notify(db, user.ID, model.NotificationGroupInvitation, &userID, model.ResourceGroup, group.ID, actorName(db, userID)+" invited you to the group \""+group.Name+"\"")
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation sent",
+		"data":    invitation,
+	})
+}
+
+// GetGroupInvitations lists the user's pending group invitations
+func GetGroupInvitations(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var invitations []model.GroupInvitation
+	if err := db.Preload("Group").
+		Where("invitee_id = ? AND status = ?", userID, model.InvitationPending).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve invitations",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitations retrieved successfully",
+		"data":    invitations,
+	})
+}
+
+// AcceptGroupInvitation joins the group with the invited role
+func AcceptGroupInvitation(c *fiber.Ctx) error {
+	return respondToGroupInvitation(c, true)
+}
+
+// DeclineGroupInvitation turns a group invitation down
+func DeclineGroupInvitation(c *fiber.Ctx) error {
+	return respondToGroupInvitation(c, false)
+}
+
+func respondToGroupInvitation(c *fiber.Ctx, accept bool) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var invitation model.GroupInvitation
+	if err := db.First(&invitation, "id = ?", c.Params("invitation_id")).Error; err != nil ||
+		invitation.InviteeID != userID || invitation.Status != model.InvitationPending {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invitation not found",
+			"data":    nil,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if !accept {
+			return tx.Model(&invitation).Update("status", model.InvitationDeclined).Error
+		}
+
+		var group model.Group
+		if err := tx.First(&group, "id = ?", invitation.GroupID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&invitation).Update("status", model.InvitationAccepted).Error; err != nil {
+			return err
+		}
+		if groupRole(tx, group.ID, userID) != "" {
+			return nil
+		}
+		return tx.Create(&model.GroupMember{
+			GroupID: group.ID,
+			UserID:  userID,
+			Role:    invitation.Role,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't respond to invitation",
+			"errors":  err.Error(),
+		})
+	}
+
+	message := "Invitation declined"
+	if accept {
+		message = "Invitation accepted"
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    invitation,
+	})
+}
+
+// UpdateGroupMember changes a member's role
+func UpdateGroupMember(c *fiber.Ctx) error {
+	type UpdateMemberInput struct {
+		Role string `json:"role" validate:"required,oneof=admin member"`
+	}
+
+	var input UpdateMemberInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	group, _, err := loadGroup(c, true)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	var member model.GroupMember
+	if err := db.Where("group_id = ? AND user_id = ?", group.ID, c.Params("user_id")).First(&member).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Member not found",
+			"data":    nil,
+		})
+	}
+
+	if member.Role == model.GroupRoleAdmin && input.Role != model.GroupRoleAdmin && lastGroupAdmin(db, group.ID) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A group needs at least one admin",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Model(&member).Update("role", input.Role).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update member",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member role updated",
+		"data":    member,
+	})
+}
+
+// RemoveGroupMember removes a member from a group. Members may leave on
+// their own; removing anyone else requires the admin role.
+func RemoveGroupMember(c *fiber.Ctx) error {
+	group, userID, err := loadGroup(c, false)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	var member model.GroupMember
+	if err := db.Where("group_id = ? AND user_id = ?", group.ID, c.Params("user_id")).First(&member).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Member not found",
+			"data":    nil,
+		})
+	}
+
+	if member.UserID != userID && groupRole(db, group.ID, userID) != model.GroupRoleAdmin {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only group admins can remove other members",
+			"data":    nil,
+		})
+	}
+
+	if member.Role == model.GroupRoleAdmin && lastGroupAdmin(db, group.ID) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A group needs at least one admin",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Unscoped().Delete(&member).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't remove member",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member removed",
+		"data":    nil,
+	})
+}
+
+// lastGroupAdmin reports whether a group has only one admin left
+func lastGroupAdmin(db *gorm.DB, groupID uint) bool {
+	var admins int64
+	db.Model(&model.GroupMember{}).Where("group_id = ? AND role = ?", groupID, model.GroupRoleAdmin).Count(&admins)
+	return admins <= 1
+}
+
+// CreateGroupGoal creates a goal shared by every member of a group
+func CreateGroupGoal(c *fiber.Ctx) error {
+	type HabitInput struct {
+		Name      string `json:"name"`
+		Frequency string `json:"frequency"`
+	}
+	type SubgoalInput struct {
+		Name string `json:"name"`
+	}
+	type CreateGroupGoalInput struct {
+		Name        string         `json:"name" validate:"required,min=1"`
+		Deadline    time.Time      `json:"deadline"`
+		Description string         `json:"description"`
+		Target      float64        `json:"target" validate:"min=0"` // Per member, 0 means any contribution counts
+		Subgoals    []SubgoalInput `json:"subgoals"`
+		Habits      []HabitInput   `json:"habits"`
+	}
+
+	var input CreateGroupGoalInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	group, userID, err := loadGroup(c, true)
+	if group == nil {
+		return err
+	}
+
+	goal := model.Goal{
+		UserID:      userID,
+		GroupID:     &group.ID,
+		Name:        input.Name,
+		Deadline:    input.Deadline,
+		Description: input.Description,
+		Target:      input.Target,
+	}
+	for _, subgoal := range input.Subgoals {
+		goal.Subgoals = append(goal.Subgoals, model.Subgoal{Name: subgoal.Name})
+	}
+	for _, habit := range input.Habits {
+		goal.Habits = append(goal.Habits, model.Habit{Name: habit.Name, Frequency: habit.Frequency})
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&goal).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceGoal, goal.ID, &goal.ID, model.ActivityCreated, goal.Name)
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create goal",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group goal created successfully",
+		"data":    goal,
+	})
+}
+
+// MemberContribution is one member's share of a group goal
+type MemberContribution struct {
+	UserID   uint    `json:"user_id"`
+	Username string  `json:"username"`
+	Total    float64 `json:"total"`
+	Progress float64 `json:"progress"` // Toward the per-member target, capped at 1
+}
+
+// groupGoalContributions aggregates each current member's progress entries
+// for a group goal, and the goal's overall progress: the average of the
+// members' progress toward the per-member target, or the share of members
+// who contributed at all when the goal has no target
+func groupGoalContributions(db *gorm.DB, goal *model.Goal) ([]MemberContribution, float64, error) {
+	contributions := []MemberContribution{}
+	if err := db.Model(&model.GroupMember{}).
+		Select("users.id AS user_id, users.username, COALESCE(SUM(progress_entries.amount), 0) AS total").
+		Joins("JOIN users ON users.id = group_members.user_id AND users.deleted_at IS NULL").
+		Joins("LEFT JOIN progress_entries ON progress_entries.user_id = group_members.user_id AND progress_entries.goal_id = ? AND progress_entries.deleted_at IS NULL", goal.ID).
+		Where("group_members.group_id = ?", *goal.GroupID).
+		Group("users.id, users.username").
+		Order("total DESC, users.username ASC").
+		Scan(&contributions).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if len(contributions) == 0 {
+		return contributions, 0, nil
+	}
+	sum := 0.0
+	for i := range contributions {
+		switch {
+		case goal.Target > 0:
+			contributions[i].Progress = contributions[i].Total / goal.Target
+			if contributions[i].Progress > 1 {
+				contributions[i].Progress = 1
+			}
+		case contributions[i].Total > 0:
+			contributions[i].Progress = 1
+		}
+		sum += contributions[i].Progress
+	}
+	return contributions, sum / float64(len(contributions)), nil
+}
+
+// GetGroupGoals lists a group's goals with each member's contribution
+func GetGroupGoals(c *fiber.Ctx) error {
+	type GroupGoalOutput struct {
+		model.Goal
+		Contributions []MemberContribution `json:"contributions"`
+	}
+
+	group, _, err := loadGroup(c, false)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	var goals []model.Goal
+	if err := db.Where("group_id = ?", group.ID).Preload("Subgoals").Preload("Habits").
+		Order("created_at ASC").Find(&goals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch goals",
+			"errors":  err.Error(),
+		})
+	}
+	fillGoalCommentCounts(db, goals)
+
+	output := []GroupGoalOutput{}
+	for i := range goals {
+		contributions, progress, err := groupGoalContributions(db, &goals[i])
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't fetch contributions",
+				"errors":  err.Error(),
+			})
+		}
+		goals[i].Progress = progress
+		output = append(output, GroupGoalOutput{Goal: goals[i], Contributions: contributions})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group goals retrieved successfully",
+		"data":    output,
+	})
+}
+
+// AddGroupGoalProgress records the caller's contribution to a group goal
+func AddGroupGoalProgress(c *fiber.Ctx) error {
+	type ProgressInput struct {
+		Amount float64 `json:"amount" validate:"required,gt=0"`
+		Note   string  `json:"note"`
+		Date   string  `json:"date" validate:"omitempty,datetime=2006-01-02"` // Defaults to today
+	}
+
+	var input ProgressInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	group, userID, err := loadGroup(c, false)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	var goal model.Goal
+	if err := db.Where("group_id = ?", group.ID).First(&goal, c.Params("goal_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if input.Date != "" {
+		date, _ = time.Parse("2006-01-02", input.Date)
+	}
+
+	entry := model.ProgressEntry{
+		GoalID: goal.ID,
+		UserID: userID,
+		Amount: input.Amount,
+		Note:   input.Note,
+		Date:   date,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't record progress",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Progress recorded",
+		"data":    entry,
+	})
+}
+
+// GetGroupFeed returns recent activity in a group: members joining, goals
+// being created and progress being recorded, newest first
+func GetGroupFeed(c *fiber.Ctx) error {
+	type FeedItem struct {
+		Type     string    `json:"type"` // "member_joined", "goal_created" or "progress"
+		UserID   uint      `json:"user_id"`
+		Username string    `json:"username"`
+		GoalID   uint      `json:"goal_id,omitempty"`
+		GoalName string    `json:"goal_name,omitempty"`
+		Amount   float64   `json:"amount,omitempty"`
+		Note     string    `json:"note,omitempty"`
+		At       time.Time `json:"at"`
+	}
+
+	group, _, err := loadGroup(c, false)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	var progress, joined, created []FeedItem
+	if err := db.Model(&model.GroupMember{}).
+		Select("'member_joined' AS type, users.id AS user_id, users.username, group_members.created_at AS at").
+		Joins("JOIN users ON users.id = group_members.user_id").
+		Where("group_members.group_id = ?", group.ID).
+		Order("group_members.created_at DESC").Limit(groupFeedSize).
+		Scan(&joined).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch activity",
+			"errors":  err.Error(),
+		})
+	}
+	if err := db.Model(&model.Goal{}).
+		Select("'goal_created' AS type, users.id AS user_id, users.username, goals.id AS goal_id, goals.name AS goal_name, goals.created_at AS at").
+		Joins("JOIN users ON users.id = goals.user_id").
+		Where("goals.group_id = ?", group.ID).
+		Order("goals.created_at DESC").Limit(groupFeedSize).
+		Scan(&created).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch activity",
+			"errors":  err.Error(),
+		})
+	}
+	if err := db.Model(&model.ProgressEntry{}).
+		Select("'progress' AS type, users.id AS user_id, users.username, goals.id AS goal_id, goals.name AS goal_name, progress_entries.amount, progress_entries.note, progress_entries.created_at AS at").
+		Joins("JOIN users ON users.id = progress_entries.user_id").
+		Joins("JOIN goals ON goals.id = progress_entries.goal_id AND goals.deleted_at IS NULL").
+		Where("goals.group_id = ?", group.ID).
+		Order("progress_entries.created_at DESC").Limit(groupFeedSize).
+		Scan(&progress).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch activity",
+			"errors":  err.Error(),
+		})
+	}
+
+	items := append(append(append([]FeedItem{}, progress...), joined...), created...)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At.After(items[j].At) })
+	if len(items) > groupFeedSize {
+		items = items[:groupFeedSize]
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group activity retrieved successfully",
+		"data":    items,
+	})
+}
+
+// GetGroupLeaderboard ranks members by their total contribution across the
+// group's goals, or to a single goal when goal_id is given. Members with
+// equal totals share a rank.
+func GetGroupLeaderboard(c *fiber.Ctx) error {
+	type LeaderboardEntry struct {
+		Rank     int     `json:"rank"`
+		UserID   uint    `json:"user_id"`
+		Username string  `json:"username"`
+		Total    float64 `json:"total"`
+	}
+
+	group, _, err := loadGroup(c, false)
+	if group == nil {
+		return err
+	}
+
+	db := database.DB
+	goals := db.Model(&model.Goal{}).Select("id").Where("group_id = ?", group.ID)
+	if v := c.Query("goal_id"); v != "" {
+		goalID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid goal ID format",
+				"data":    nil,
+			})
+		}
+		goals = goals.Where("id = ?", uint(goalID))
+	}
+
+	entries := []LeaderboardEntry{}
+	if err := db.Model(&model.GroupMember{}).
+		Select("users.id AS user_id, users.username, COALESCE(SUM(progress_entries.amount), 0) AS total").
+		Joins("JOIN users ON users.id = group_members.user_id AND users.deleted_at IS NULL").
+		Joins("LEFT JOIN progress_entries ON progress_entries.user_id = group_members.user_id AND progress_entries.deleted_at IS NULL AND progress_entries.goal_id IN (?)", goals).
+		Where("group_members.group_id = ?", group.ID).
+		Group("users.id, users.username").
+		Order("total DESC, users.username ASC").
+		Scan(&entries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch leaderboard",
+			"errors":  err.Error(),
+		})
+	}
+
+	for i := range entries {
+		if i > 0 && entries[i].Total == entries[i-1].Total {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Leaderboard retrieved successfully",
+		"data":    entries,
+	})
+}
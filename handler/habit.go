@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CheckInHabit records that a habit was done on a day (today by default)
+func CheckInHabit(c *fiber.Ctx) error {
+	return habitCheckInAction(c, true)
+}
+
+// UndoHabitCheckIn removes the check-in for a day (today by default)
+func UndoHabitCheckIn(c *fiber.Ctx) error {
+	return habitCheckInAction(c, false)
+}
+
+func habitCheckInAction(c *fiber.Ctx, checkIn bool) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	// Days are given as YYYY-MM-DD
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid date format, expected YYYY-MM-DD",
+				"data":    nil,
+			})
+		}
+		day = parsed
+	}
+
+	db := database.DB
+	var goal model.Goal
+	if err := db.First(&goal, c.Params("goal_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+
+	if goal.UserID != userID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to check in this habit",
+			"data":    nil,
+		})
+	}
+
+	var habit model.Habit
+	if err := db.Where("goal_id = ? AND id = ?", goal.ID, c.Params("habit_id")).First(&habit).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Habit not found",
+		})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if checkIn {
+			// Checking in twice on the same day is a no-op
+			err = tx.Where(model.HabitCheckIn{HabitID: habit.ID, Date: day}).FirstOrCreate(&model.HabitCheckIn{}).Error
+		} else {
+			err = tx.Unscoped().Where("habit_id = ? AND date = ?", habit.ID, day).Delete(&model.HabitCheckIn{}).Error
+		}
+		if err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceHabit, habit.ID, &goal.ID, completionAction(checkIn), habit.Name)
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update habit check-in",
+			"errors":  err.Error(),
+		})
+	}
+
+	goals := []model.Goal{{Habits: []model.Habit{habit}}}
+	if err := database.FillHabitStreaks(db, goals); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch habit streaks",
+			"errors":  err.Error(),
+		})
+	}
+
+	message := "Habit checked in"
+	if !checkIn {
+		message = "Habit check-in removed"
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    goals[0].Habits[0],
+	})
+}
@@ -0,0 +1,633 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/database"
+	"app/importer"
+	"app/jobs"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportResult reports what an import created, or would create on a dry run
+type ImportResult struct {
+	DryRun   bool                `json:"dry_run"`
+	List     *model.TaskList     `json:"list"`  // Nil on a dry run
+	Tasks    []importer.Task     `json:"tasks"` // Preview of the tasks, only on a dry run
+	Imported int                 `json:"imported"`
+	Errors   []importer.RowError `json:"errors"`
+}
+
+// ImportTasks creates a task list from an exported file. The file comes as
+// the multipart field "file" or as text in "content". Rows that can't be
+// read are skipped and reported; everything else is created in a single
+// transaction. With dry_run nothing is saved and the parsed tasks are
+// returned for preview.
+func ImportTasks(c *fiber.Ctx) error {
+	type ImportInput struct {
+		Format  string `json:"format" form:"format" validate:"required,oneof=ics csv"`
+		Name    string `json:"name" form:"name" validate:"max=100"` // List name, defaults to the calendar's name or "Imported tasks"
+		Content string `json:"content" form:"content"`
+		Mapping string `json:"mapping" form:"mapping"` // CSV column mapping as JSON, see importer.Mapping
+		DryRun  bool   `json:"dry_run" form:"dry_run"`
+	}
+
+	var input ImportInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Validate input
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var file io.Reader = strings.NewReader(input.Content)
+	if header, err := c.FormFile("file"); err == nil {
+		f, err := header.Open()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't read the uploaded file",
+				"errors":  err.Error(),
+			})
+		}
+		defer f.Close()
+		file = f
+	} else if input.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Upload a file or send its content",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+			"errors":  err.Error(),
+		})
+	}
+	loc := database.UserLocation(&user)
+
+	var (
+		result *importer.Result
+		err    error
+	)
+	switch input.Format {
+	case "ics":
+		result, err = importer.ParseICS(file, loc)
+	case "csv":
+		var mapping importer.Mapping
+		if input.Mapping != "" {
+			decoder := json.NewDecoder(bytes.NewReader([]byte(input.Mapping)))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&mapping); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Invalid column mapping",
+					"errors":  err.Error(),
+				})
+			}
+		}
+		result, err = importer.ParseCSV(file, mapping, loc)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't read the file",
+			"errors":  err.Error(),
+		})
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = result.Name
+	}
+	if name == "" {
+		name = "Imported tasks"
+	}
+
+	if input.DryRun {
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "Import preview",
+			"data": ImportResult{
+				DryRun:   true,
+				Tasks:    result.Tasks,
+				Imported: len(result.Tasks),
+				Errors:   result.Errors,
+			},
+		})
+	}
+
+	if len(result.Tasks) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The file contains no tasks that can be imported",
+			"errors":  result.Errors,
+		})
+	}
+
+	list := model.TaskList{UserID: userID, Name: name}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&list).Error; err != nil {
+			return err
+		}
+		if err := createImportedTasks(tx, userID, list.ID, nil, result.Tasks, map[string]model.Label{}, nil); err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceList, list.ID, nil, model.ActivityCreated, list.Name)
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't import tasks",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Tasks imported successfully",
+		"data": ImportResult{
+			List:     &list,
+			Imported: len(result.Tasks),
+			Errors:   result.Errors,
+		},
+	})
+}
+
+// createImportedTasks creates imported tasks and their subtasks in a list,
+// along with the user's labels they need. labels caches labels by name
+// across calls; created, if set, is called with the number of tasks after
+// every insert.
+func createImportedTasks(tx *gorm.DB, userID, listID uint, parentID *uint, imported []importer.Task, labels map[string]model.Label, created func(int)) error {
+	if len(imported) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	tasks := make([]model.Task, len(imported))
+	for i, t := range imported {
+		task := model.Task{
+			TaskListID: listID,
+			Text:       t.Text,
+			DueDate:    t.DueDate,
+			ParentID:   parentID,
+		}
+		if t.Completed {
+			completedAt := now
+			if t.CompletedAt != nil {
+				completedAt = *t.CompletedAt
+			}
+			task.Completed = true
+			task.CompletedByID = &userID
+			task.CompletedAt = &completedAt
+		}
+		for _, name := range t.Labels {
+			label, ok := labels[name]
+			if !ok {
+				if err := tx.Where(model.Label{UserID: userID, Name: name}).FirstOrCreate(&label).Error; err != nil {
+					return err
+				}
+				labels[name] = label
+			}
+			task.Labels = append(task.Labels, label)
+		}
+		tasks[i] = task
+	}
+
+	if err := tx.CreateInBatches(&tasks, 500).Error; err != nil {
+		return err
+	}
+	if created != nil {
+		created(len(tasks))
+	}
+
+	for i := range imported {
+		if err := createImportedTasks(tx, userID, listID, &tasks[i].ID, imported[i].Subtasks, labels, created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JobRunImport imports an export from another tool in the background
+const JobRunImport = "import.run"
+
+// RunImportPayload is the payload of JobRunImport
+type RunImportPayload struct {
+	ImportID uint `json:"import_id"`
+}
+
+// importProgressStep is how many tasks are created between progress updates
+const importProgressStep = 100
+
+// RegisterJobs registers the job types handled here with the job runner.
+// Call it before the runner starts.
+func RegisterJobs() {
+	jobs.Register(JobRunImport, jobs.Config{Timeout: 30 * time.Minute, MaxAttempts: 3}, runImport)
+	jobs.OnFailure(JobRunImport, importFailed)
+	jobs.Register(JobBuildExport, jobs.Config{Timeout: 30 * time.Minute, MaxAttempts: 3}, buildExport)
+	jobs.OnFailure(JobBuildExport, exportFailed)
+}
+
+// errImportBusy is returned when a failed import's job still holds its
+// unique key
+var errImportBusy = errors.New("import is still finishing")
+
+// StartImport queues the import of a Todoist, Trello or Microsoft To Do
+// export uploaded as the multipart field "file". The Idempotency-Key header
+// identifies the import; without one the file's hash does, so uploading the
+// same export twice returns the first import instead of duplicating it. An
+// import that failed for good is replaced by the new upload.
+func StartImport(c *fiber.Ctx) error {
+	type StartImportInput struct {
+		Source string `json:"source" form:"source" validate:"required,oneof=todoist trello mstodo"`
+	}
+
+	var input StartImportInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Validate input
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Upload the exported file",
+			"data":    nil,
+		})
+	}
+	f, err := header.Open()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't read the uploaded file",
+			"errors":  err.Error(),
+		})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't read the uploaded file",
+			"errors":  err.Error(),
+		})
+	}
+
+	key := strings.TrimSpace(c.Get("Idempotency-Key"))
+	if key == "" {
+		sum := sha256.Sum256(append([]byte(input.Source+"\n"), data...))
+		key = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if len(key) > 255 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The idempotency key can be at most 255 characters",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	var (
+		imp     model.Import
+		started bool
+	)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND idempotency_key = ?", userID, key).
+			Limit(1).Find(&imp).Error; err != nil {
+			return err
+		}
+		switch {
+		case imp.ID == 0:
+			imp = model.Import{
+				UserID:         userID,
+				IdempotencyKey: key,
+				Source:         input.Source,
+				FileName:       header.Filename,
+				Data:           data,
+				Status:         model.ImportQueued,
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&imp)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// A concurrent upload with the same key got there first
+				return tx.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&imp).Error
+			}
+		case imp.Status == model.ImportFailed && imp.FinishedAt != nil:
+			// Until then the job is still retrying the earlier upload
+			imp.Source = input.Source
+			imp.FileName = header.Filename
+			imp.Data = data
+			imp.Status = model.ImportQueued
+			imp.Total, imp.Processed = 0, 0
+			imp.ListIDs, imp.Errors = nil, nil
+			imp.Error = ""
+			imp.FinishedAt = nil
+			if err := tx.Save(&imp).Error; err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		job, err := jobs.Enqueue(tx, JobRunImport, RunImportPayload{ImportID: imp.ID},
+			jobs.Options{UniqueKey: "import:" + strconv.FormatUint(uint64(imp.ID), 10)})
+		if err == nil && job == nil {
+			// The earlier job has failed the import but isn't done yet
+			return errImportBusy
+		}
+		started = err == nil
+		return err
+	})
+	if errors.Is(err, errImportBusy) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The previous import of this file is still finishing, try again shortly",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't start import",
+			"errors":  err.Error(),
+		})
+	}
+
+	if !started {
+		message := "This file is already being imported"
+		if imp.Status == model.ImportSucceeded {
+			message = "This file has already been imported"
+		}
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": message,
+			"data":    imp,
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Import started",
+		"data":    imp,
+	})
+}
+
+// GetImports lists the user's imports, newest first
+func GetImports(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	page, limit := pageParams(c)
+	imports := []model.Import{}
+	// Fetch one extra row to know whether there is a next page
+	if err := database.DB.Omit("data").Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).Limit(limit + 1).
+		Find(&imports).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve imports",
+			"errors":  err.Error(),
+		})
+	}
+
+	hasMore := len(imports) > limit
+	if hasMore {
+		imports = imports[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Imports retrieved successfully",
+		"data": fiber.Map{
+			"items":    imports,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+		},
+	})
+}
+
+// GetImport returns an import's status and progress
+func GetImport(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var imp model.Import
+	if err := database.DB.Omit("data").Where("id = ? AND user_id = ?", c.Params("import_id"), userID).First(&imp).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Import not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Import retrieved successfully",
+		"data":    imp,
+	})
+}
+
+// runImport creates the lists and tasks of an import in one transaction, so
+// a failed attempt leaves nothing behind and can simply be retried. Progress
+// is written outside the transaction to be visible while it runs.
+func runImport(ctx context.Context, payload RunImportPayload) error {
+	db := database.DB
+	var imp model.Import
+	if err := db.First(&imp, payload.ImportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if imp.Status == model.ImportSucceeded {
+		return nil
+	}
+
+	var user model.User
+	if err := db.First(&user, imp.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// A file that can't be read won't get better on a retry
+	export, err := importer.Parse(imp.Source, imp.Data, imp.FileName, database.UserLocation(&user))
+	if err != nil {
+		return finishImport(db, &imp, err)
+	}
+	rowErrors := make([]model.ImportRowError, len(export.Errors))
+	for i, e := range export.Errors {
+		rowErrors[i] = model.ImportRowError{Row: e.Row, Error: e.Error}
+	}
+
+	imp.Status = model.ImportRunning
+	imp.Total = export.Count()
+	imp.Processed = 0
+	imp.Error = ""
+	if err := db.Model(&imp).Select("status", "total", "processed", "error").Updates(&imp).Error; err != nil {
+		return err
+	}
+
+	processed, reported := 0, 0
+	progress := func(n int) {
+		processed += n
+		if processed-reported >= importProgressStep {
+			reported = processed
+			if err := db.Model(&model.Import{}).Where("id = ?", imp.ID).Update("processed", processed).Error; err != nil {
+				log.Println("Error updating import progress: ", err.Error())
+			}
+		}
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		labels := map[string]model.Label{}
+		listIDs := []uint{}
+		for _, l := range export.Lists {
+			list := model.TaskList{UserID: imp.UserID, Name: l.Name}
+			if err := tx.Create(&list).Error; err != nil {
+				return err
+			}
+			if err := createImportedTasks(tx, imp.UserID, list.ID, nil, l.Tasks, labels, progress); err != nil {
+				return err
+			}
+			if err := recordActivity(tx, imp.UserID, model.ResourceList, list.ID, nil, model.ActivityCreated, list.Name); err != nil {
+				return err
+			}
+			listIDs = append(listIDs, list.ID)
+		}
+
+		now := time.Now()
+		imp.Status = model.ImportSucceeded
+		imp.Processed = processed
+		imp.ListIDs = listIDs
+		imp.Errors = rowErrors
+		imp.Data = nil
+		imp.FinishedAt = &now
+		return tx.Save(&imp).Error
+	})
+	if err != nil {
+		// Let the job runner retry; the import shows as failed meanwhile and
+		// importFailed finishes it once the attempts run out
+		if updateErr := db.Model(&imp).Updates(map[string]interface{}{
+			"status":    model.ImportFailed,
+			"processed": 0,
+			"error":     err.Error(),
+		}).Error; updateErr != nil {
+			log.Println("Error updating import: ", updateErr.Error())
+		}
+		return err
+	}
+
+	return finishImport(db, &imp, nil)
+}
+
+// importFailed finishes an import whose job has run out of attempts
+func importFailed(payload RunImportPayload, err error) {
+	db := database.DB
+	var imp model.Import
+	if db.First(&imp, payload.ImportID).Error != nil || imp.Status == model.ImportSucceeded {
+		return
+	}
+	if err := finishImport(db, &imp, err); err != nil {
+		log.Println("Error finishing import: ", err.Error())
+	}
+}
+
+// finishImport marks an import as failed if err is set and tells the user
+// how it went
+func finishImport(db *gorm.DB, imp *model.Import, err error) error {
+	var message string
+	if err != nil {
+		now := time.Now()
+		imp.Status = model.ImportFailed
+		imp.Error = err.Error()
+		imp.FinishedAt = &now
+		if err := db.Model(imp).Select("status", "error", "finished_at").Updates(imp).Error; err != nil {
+			return err
+		}
+		message = "Your import of " + imp.FileName + " failed: " + imp.Error
+	} else {
+		message = "Your import of " + imp.FileName + " is done: " + strconv.Itoa(imp.Processed) + " tasks imported"
+		if len(imp.Errors) > 0 {
+			message += ", " + strconv.Itoa(len(imp.Errors)) + " with problems"
+		}
+	}
+	notify(db, imp.UserID, model.NotificationImport, nil, model.ResourceImport, imp.ID, message)
+	return nil
+}
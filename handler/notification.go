@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"log"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// notify sends a notification after a successful write. Failing to send it
+// doesn't fail the request that caused it; the notification is nil then, or
+// when the user doesn't want it.
+func notify(db *gorm.DB, userID uint, kind string, actorID *uint, resourceType string, resourceID uint, message string) *model.Notification {
+	notification, err := database.Notify(db, userID, kind, actorID, resourceType, resourceID, message)
+	if err != nil {
+		log.Println("Error creating notification: ", err.Error())
+		return nil
+	}
+	return notification
+}
+
+// actorName returns the username to show in notifications about a user's
+// actions
+func actorName(db *gorm.DB, userID uint) string {
+	var user model.User
+	if err := db.Select("username").First(&user, userID).Error; err != nil {
+		return "Someone"
+	}
+	return user.Username
+}
+
+// GetNotifications returns a page of the user's delivered notifications,
+// newest first, with the number of unread ones. With ?unread=true only
+// unread notifications are returned.
+func GetNotifications(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	now := time.Now()
+	var unread int64
+	if err := db.Model(&model.Notification{}).
+		Where("user_id = ? AND deliver_at <= ? AND read_at IS NULL", userID, now).
+		Count(&unread).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve notifications",
+			"errors":  err.Error(),
+		})
+	}
+
+	page, limit := pageParams(c)
+	query := db.Where("user_id = ? AND deliver_at <= ?", userID, now)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	notifications := []model.Notification{}
+	// Fetch one extra row to know whether there is a next page
+	if err := query.Order("deliver_at DESC, id DESC").
+		Offset((page - 1) * limit).Limit(limit + 1).
+		Find(&notifications).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve notifications",
+			"errors":  err.Error(),
+		})
+	}
+
+	hasMore := len(notifications) > limit
+	if hasMore {
+		notifications = notifications[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Notifications retrieved successfully",
+		"data": fiber.Map{
+			"items":    notifications,
+			"unread":   unread,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+		},
+	})
+}
+
+// MarkNotificationRead marks one of the user's notifications as read
+func MarkNotificationRead(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var notification model.Notification
+	if err := db.Where("id = ? AND user_id = ?", c.Params("notification_id"), userID).First(&notification).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Notification not found",
+			"data":    nil,
+		})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := db.Save(&notification).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't update notification",
+				"errors":  err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Notification marked as read",
+		"data":    notification,
+	})
+}
+
+// MarkAllNotificationsRead marks every delivered notification of the user as
+// read
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	now := time.Now()
+	res := database.DB.Model(&model.Notification{}).
+		Where("user_id = ? AND deliver_at <= ? AND read_at IS NULL", userID, now).
+		Update("read_at", now)
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update notifications",
+			"errors":  res.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Notifications marked as read",
+		"data":    fiber.Map{"updated": res.RowsAffected},
+	})
+}
+
+// GetNotificationPreferences returns whether each notification type is on
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	preferences := make(map[string]bool)
+	for _, kind := range model.NotificationTypes {
+		preferences[kind] = database.NotificationEnabled(database.DB, userID, kind)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Notification preferences retrieved successfully",
+		"data":    preferences,
+	})
+}
+
+// UpdateNotificationPreferences turns notification types on or off. The body
+// maps types to whether they are on; types left out are unchanged.
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+	var input map[string]bool
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	known := make(map[string]bool)
+	for _, kind := range model.NotificationTypes {
+		known[kind] = true
+	}
+	for kind := range input {
+		if !known[kind] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unknown notification type: " + kind,
+				"data":    nil,
+			})
+		}
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for kind, enabled := range input {
+			preference := model.NotificationPreference{UserID: userID, Type: kind}
+			if err := tx.Where(preference).FirstOrInit(&preference).Error; err != nil {
+				return err
+			}
+			preference.Enabled = enabled
+			if err := tx.Save(&preference).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update notification preferences",
+			"errors":  err.Error(),
+		})
+	}
+
+	preferences := make(map[string]bool)
+	for _, kind := range model.NotificationTypes {
+		preferences[kind] = database.NotificationEnabled(db, userID, kind)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Notification preferences updated",
+		"data":    preferences,
+	})
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// nudgeLimit is how many nudges one user may send another per nudgeWindow
+const (
+	nudgeLimit  = 3
+	nudgeWindow = 24 * time.Hour
+)
+
+// SendNudge reminds a partner about one of their open goals or overdue tasks
+func SendNudge(c *fiber.Ctx) error {
+	type NudgeInput struct {
+		GoalID  uint   `json:"goal_id" validate:"required_without=TaskID"`
+		TaskID  uint   `json:"task_id" validate:"required_without=GoalID"`
+		Message string `json:"message" validate:"max=280"`
+	}
+
+	var input NudgeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	nudge := model.Nudge{SenderID: userID, Message: input.Message}
+	var subject string
+
+	if input.GoalID != 0 {
+		var goal model.Goal
+		if err := db.First(&goal, input.GoalID).Error; err != nil || goal.GroupID != nil || !canViewGoal(db, &goal, userID) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Goal not found",
+				"data":    nil,
+			})
+		}
+		if goal.Completed {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "This goal is already completed",
+				"data":    nil,
+			})
+		}
+		nudge.RecipientID = goal.UserID
+		nudge.ResourceType = model.ResourceGoal
+		nudge.ResourceID = goal.ID
+		subject = goal.Name
+	} else {
+		var task model.Task
+		var list model.TaskList
+		if err := db.First(&task, input.TaskID).Error; err != nil || db.First(&list, task.TaskListID).Error != nil {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Task not found",
+				"data":    nil,
+			})
+		}
+		// Partners see tasks through the goals they are linked to
+		if !canAccessResource(db, model.ResourceTask, task.ID, userID) &&
+			(task.GoalID == nil || !canAccessResource(db, model.ResourceGoal, *task.GoalID, userID)) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Task not found",
+				"data":    nil,
+			})
+		}
+		if task.Completed || task.DueDate == nil || !task.DueDate.Before(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Only overdue tasks can be nudged",
+				"data":    nil,
+			})
+		}
+		nudge.RecipientID = list.UserID
+		nudge.ResourceType = model.ResourceTask
+		nudge.ResourceID = task.ID
+		subject = task.Text
+	}
+
+	if nudge.RecipientID == userID || !arePartners(db, userID, nudge.RecipientID) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You can only nudge your accountability partners",
+			"data":    nil,
+		})
+	}
+
+	var recipient model.User
+	if err := db.First(&recipient, nudge.RecipientID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+			"data":    nil,
+		})
+	}
+	if !recipient.AllowNudges {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This user has turned off nudges",
+			"data":    nil,
+		})
+	}
+
+	message := actorName(db, userID) + " nudged you about \"" + subject + "\""
+	if nudge.Message != "" {
+		message += ": " + nudge.Message
+	}
+
+	// Nudges between the same pair of users are sent one at a time, so that
+	// concurrent requests can't get past the limit
+	errTooMany := errors.New("too many nudges")
+	errNotDelivered := errors.New("nudge not delivered")
+	var notification *model.Notification
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", userID, recipient.ID).Error; err != nil {
+			return err
+		}
+		var recent int64
+		if err := tx.Model(&model.Nudge{}).
+			Where("sender_id = ? AND recipient_id = ? AND created_at > ?", userID, recipient.ID, time.Now().Add(-nudgeWindow)).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent >= nudgeLimit {
+			return errTooMany
+		}
+		if err := tx.Create(&nudge).Error; err != nil {
+			return err
+		}
+		var err error
+		notification, err = database.Notify(tx, recipient.ID, model.NotificationNudge, &userID, nudge.ResourceType, nudge.ResourceID, message)
+		if err == nil && notification == nil {
+			err = errNotDelivered
+		}
+		return err
+	})
+	if errors.Is(err, errTooMany) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You've nudged this user too often, try again later",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't send nudge",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Nudge sent",
+		"data": fiber.Map{
+			"nudge":      nudge,
+			"deliver_at": notification.DeliverAt,
+		},
+	})
+}
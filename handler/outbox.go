@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"app/database"
+	"app/model"
+	"app/outbox"
+	"app/pubsub"
+)
+
+// Outbox subscribers
+const (
+	subscriberStream   = "stream"
+	subscriberWebhooks = "webhooks"
+)
+
+// SubscribeToOutbox registers the handlers that pass outbox events on to
+// live streams and webhooks. Call it before the relay starts.
+func SubscribeToOutbox() {
+	outbox.Subscribe(subscriberStream, streamEvent)
+	outbox.Subscribe(subscriberWebhooks, queueWebhooks)
+}
+
+// streamEvent pushes an outbox event to the live streams of everyone who can
+// see its resource, or of the recipient for notifications. Streams only show
+// what happens while they are open, so an event handed over twice is
+// harmless, and one that can't be published at all is dropped rather than
+// holding up the events after it.
+func streamEvent(ctx context.Context, event *model.OutboxEvent) error {
+	var err error
+	if event.Type == database.EventNotificationCreated {
+		// Notifications only go to their recipient
+		var notification model.Notification
+		if err := outbox.Decode(event, &notification); err != nil {
+			return err
+		}
+		err = database.PublishNotification(&notification)
+	} else {
+		err = publish(database.DB, event.Type, event.ActorID, event.ResourceType, event.ResourceID, json.RawMessage(event.Payload))
+	}
+	if errors.Is(err, pubsub.ErrTooLarge) {
+		log.Printf("Dropping event %d from live streams: %v", event.ID, err)
+		return nil
+	}
+	return err
+}
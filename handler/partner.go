@@ -0,0 +1,425 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// findPartnership returns the partnership between two users, whichever of
+// them requested it, or nil if there is none
+func findPartnership(db *gorm.DB, a, b uint) (*model.Partnership, error) {
+	var partnership model.Partnership
+	err := db.Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)", a, b, b, a).
+		First(&partnership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &partnership, nil
+}
+
+// arePartners reports whether two users are accepted accountability partners
+func arePartners(db *gorm.DB, a, b uint) bool {
+	partnership, err := findPartnership(db, a, b)
+	return err == nil && partnership != nil && partnership.Status == model.PartnershipAccepted
+}
+
+// isBlocked reports whether either user has blocked the other
+func isBlocked(db *gorm.DB, a, b uint) bool {
+	partnership, err := findPartnership(db, a, b)
+	return err == nil && partnership != nil && partnership.Status == model.PartnershipBlocked
+}
+
+// canViewGoal reports whether a user may see a goal given its visibility
+func canViewGoal(db *gorm.DB, goal *model.Goal, viewerID uint) bool {
+	if goal.UserID == viewerID {
+		return true
+	}
+	if goal.GroupID != nil && groupRole(db, *goal.GroupID, viewerID) != "" {
+		return true
+	}
+	switch goal.Visibility {
+	case model.VisibilityPublic:
+		return !isBlocked(db, goal.UserID, viewerID)
+	case model.VisibilityPartners:
+		return arePartners(db, goal.UserID, viewerID)
+	}
+	return false
+}
+
+// GetPartners lists the user's partnerships, including pending requests in
+// both directions and users they have blocked
+func GetPartners(c *fiber.Ctx) error {
+	type PartnerOutput struct {
+		ID        uint   `json:"id"` // Partnership ID
+		UserID    uint   `json:"user_id"`
+		Username  string `json:"username"`
+		Name      string `json:"name"`
+		Status    string `json:"status"`
+		Direction string `json:"direction"` // "incoming" or "outgoing"
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var partnerships []model.Partnership
+	if err := db.Where("requester_id = ? OR addressee_id = ?", userID, userID).
+		Order("created_at ASC").Find(&partnerships).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve partners",
+			"errors":  err.Error(),
+		})
+	}
+
+	partners := []PartnerOutput{}
+	for _, p := range partnerships {
+		// Don't reveal that someone blocked the user
+		if p.Status == model.PartnershipBlocked && (p.BlockedByID == nil || *p.BlockedByID != userID) {
+			continue
+		}
+
+		out := PartnerOutput{ID: p.ID, Status: p.Status, UserID: p.AddresseeID, Direction: "outgoing"}
+		if p.AddresseeID == userID {
+			out.UserID = p.RequesterID
+			out.Direction = "incoming"
+		}
+
+		var user model.User
+		if err := db.First(&user, out.UserID).Error; err != nil {
+			continue
+		}
+		out.Username = user.Username
+		out.Name = user.Name
+		partners = append(partners, out)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Partners retrieved successfully",
+		"data":    partners,
+	})
+}
+
+// RequestPartner sends a partner request to another user
+func RequestPartner(c *fiber.Ctx) error {
+	userID, other, err := partnerTarget(c)
+	if err != nil {
+		return err
+	}
+
+	db := database.DB
+	existing, err := findPartnership(db, userID, other.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+			"errors":  err.Error(),
+		})
+	}
+
+	if existing != nil {
+		// Answering a pending request from the other side accepts it
+		if existing.Status == model.PartnershipPending && existing.AddresseeID == userID {
+			if err := db.Model(existing).Update("status", model.PartnershipAccepted).Error; err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Couldn't accept partner request",
+					"errors":  err.Error(),
+				})
+			}
+			notify(db, existing.RequesterID, model.NotificationPartner, &userID, "", 0, actorName(db, userID)+" accepted your partner request")
+
+			return c.JSON(fiber.Map{
+				"status":  "success",
+				"message": "Partner request accepted",
+				"data":    existing,
+			})
+		}
+		// Existing partnerships, outgoing requests and blocks can't be requested again
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A partner request already exists",
+			"data":    nil,
+		})
+	}
+
+	partnership := model.Partnership{
+		RequesterID: userID,
+		AddresseeID: other.ID,
+		Status:      model.PartnershipPending,
+	}
+	if err := db.Create(&partnership).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't send partner request",
+			"errors":  err.Error(),
+		})
+	}
+
+	notify(db, other.ID, model.NotificationPartner, &userID, "", 0, actorName(db, userID)+" wants to be your accountability partner")
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Partner request sent",
+		"data":    partnership,
+	})
+}
+
+// AcceptPartner accepts a pending partner request addressed to the user
+func AcceptPartner(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var partnership model.Partnership
+	if err := db.First(&partnership, "id = ?", c.Params("partnership_id")).Error; err != nil ||
+		partnership.AddresseeID != userID || partnership.Status != model.PartnershipPending {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Partner request not found",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Model(&partnership).Update("status", model.PartnershipAccepted).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't accept partner request",
+			"errors":  err.Error(),
+		})
+	}
+
+	notify(db, partnership.RequesterID, model.NotificationPartner, &userID, "", 0, actorName(db, userID)+" accepted your partner request")
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Partner request accepted",
+		"data":    partnership,
+	})
+}
+
+// RemovePartner ends a partnership, cancels or declines a pending request,
+// or lifts a block the user placed
+func RemovePartner(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var partnership model.Partnership
+	if err := db.First(&partnership, "id = ?", c.Params("partnership_id")).Error; err != nil ||
+		(partnership.RequesterID != userID && partnership.AddresseeID != userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Partnership not found",
+			"data":    nil,
+		})
+	}
+
+	// Only the user who placed a block can lift it
+	if partnership.Status == model.PartnershipBlocked && (partnership.BlockedByID == nil || *partnership.BlockedByID != userID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Partnership not found",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Unscoped().Delete(&partnership).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't remove partner",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Partner removed",
+		"data":    nil,
+	})
+}
+
+// BlockUser blocks another user, ending any partnership with them and any
+// follows between them
+func BlockUser(c *fiber.Ctx) error {
+	userID, other, err := partnerTarget(c)
+	if err != nil {
+		return err
+	}
+
+	db := database.DB
+	existing, err := findPartnership(db, userID, other.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+			"errors":  err.Error(),
+		})
+	}
+
+	partnership := model.Partnership{RequesterID: userID, AddresseeID: other.ID}
+	if existing != nil {
+		partnership = *existing
+	}
+	partnership.Status = model.PartnershipBlocked
+	partnership.BlockedByID = &userID
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&partnership).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)",
+			userID, other.ID, other.ID, userID).Delete(&model.Follow{}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't block user",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User blocked",
+		"data":    partnership,
+	})
+}
+
+// partnerTarget resolves the user named in a partner request body. On
+// failure the error response has already been written and is returned.
+func partnerTarget(c *fiber.Ctx) (uint, *model.User, error) {
+	type PartnerInput struct {
+		Username string `json:"username" validate:"required"`
+	}
+
+	var input PartnerInput
+	if err := c.BodyParser(&input); err != nil {
+		return 0, nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return 0, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return 0, nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	other, err := getUserByUsername(input.Username)
+	if err != nil {
+		return 0, nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+			"errors":  err.Error(),
+		})
+	}
+	if other == nil || other.ID == userID {
+		return 0, nil, c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+			"data":    nil,
+		})
+	}
+	return userID, other, nil
+}
+
+// GetPartnerGoals lists another user's goals that are visible to the caller,
+// with subgoal progress and habit streaks
+func GetPartnerGoals(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	ownerID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID format",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	visibilities := []string{model.VisibilityPublic}
+	if arePartners(db, uint(ownerID), userID) {
+		visibilities = append(visibilities, model.VisibilityPartners)
+	}
+
+	goals := []model.Goal{}
+	if uint(ownerID) != userID && !isBlocked(db, uint(ownerID), userID) {
+		if err := db.Where("user_id = ? AND group_id IS NULL AND visibility IN ?", uint(ownerID), visibilities).
+			Preload("Subgoals").Preload("Habits").
+			// Linked tasks only count toward progress; partners don't see them
+			Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Select("id", "goal_id", "completed") }).
+			Order("created_at ASC").Find(&goals).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't fetch goals",
+				"errors":  err.Error(),
+			})
+		}
+	}
+
+	if err := database.FillHabitStreaks(db, goals); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch habit streaks",
+			"errors":  err.Error(),
+		})
+	}
+	for i := range goals {
+		goals[i].Progress = model.GoalProgress(&goals[i])
+		goals[i].Tasks = nil
+	}
+	fillGoalCommentCounts(db, goals)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Goals retrieved successfully",
+		"data":    goals,
+	})
+}
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ProfileSummary is what anyone signed in can see of a user
+type ProfileSummary struct {
+	Username   string    `json:"username"`
+	Name       string    `json:"name"`
+	Occupation string    `json:"occupation"`
+	About      string    `json:"about"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+func profileSummary(user *model.User) ProfileSummary {
+	return ProfileSummary{
+		Username:   user.Username,
+		Name:       user.Name,
+		Occupation: user.Occupation,
+		About:      user.About,
+		JoinedAt:   user.CreatedAt,
+	}
+}
+
+// ProfileGoal is a goal as shown on a profile
+type ProfileGoal struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Deadline    time.Time `json:"deadline"`
+	Completed   bool      `json:"completed"`
+	Progress    float64   `json:"progress"`
+}
+
+// Achievement is a milestone shown on a profile
+type Achievement struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+}
+
+// Achievement thresholds, in order
+var (
+	goalAchievements = []struct {
+		count      int64
+		key, title string
+	}{
+		{1, "first_goal", "Completed a first goal"},
+		{5, "five_goals", "Completed 5 goals"},
+		{25, "twenty_five_goals", "Completed 25 goals"},
+	}
+	streakAchievements = []struct {
+		days       int
+		key, title string
+	}{
+		{7, "week_streak", "Kept a habit streak of 7"},
+		{30, "month_streak", "Kept a habit streak of 30"},
+		{100, "hundred_streak", "Kept a habit streak of 100"},
+	}
+)
+
+// achievements returns the milestones a user has reached across all of their
+// goals. Only counts are used, so nothing about private goals is revealed.
+func achievements(db *gorm.DB, userID uint) ([]Achievement, error) {
+	var completed int64
+	if err := db.Model(&model.Goal{}).Where("user_id = ? AND completed = ?", userID, true).Count(&completed).Error; err != nil {
+		return nil, err
+	}
+
+	var goals []model.Goal
+	if err := db.Where("user_id = ?", userID).Preload("Habits").Find(&goals).Error; err != nil {
+		return nil, err
+	}
+	if err := database.FillHabitStreaks(db, goals); err != nil {
+		return nil, err
+	}
+	longest := 0
+	for _, goal := range goals {
+		for _, habit := range goal.Habits {
+			if habit.LongestStreak > longest {
+				longest = habit.LongestStreak
+			}
+		}
+	}
+
+	list := []Achievement{}
+	for _, a := range goalAchievements {
+		if completed >= a.count {
+			list = append(list, Achievement{Key: a.key, Title: a.title})
+		}
+	}
+	for _, a := range streakAchievements {
+		if longest >= a.days {
+			list = append(list, Achievement{Key: a.key, Title: a.title})
+		}
+	}
+	return list, nil
+}
+
+// profileUser resolves the user named in the URL. Users who blocked the
+// caller, or were blocked by them, are not found. On failure the error
+// response has already been written and is returned.
+func profileUser(c *fiber.Ctx) (uint, *model.User, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return 0, nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	user, err := getUserByUsername(c.Params("username"))
+	if err != nil {
+		return 0, nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+			"errors":  err.Error(),
+		})
+	}
+	if user == nil || isBlocked(database.DB, user.ID, userID) {
+		return 0, nil, c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+			"data":    nil,
+		})
+	}
+	return userID, user, nil
+}
+
+// isFollowing reports whether follower follows followee
+func isFollowing(db *gorm.DB, followerID, followeeID uint) bool {
+	var count int64
+	db.Model(&model.Follow{}).Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Count(&count)
+	return count > 0
+}
+
+// GetProfile returns a user's public profile: their details, follow counts,
+// the goals the caller may see and their achievements
+func GetProfile(c *fiber.Ctx) error {
+	type Profile struct {
+		ProfileSummary
+		Followers    int64         `json:"followers"`
+		Following    int64         `json:"following"`
+		IsFollowing  bool          `json:"is_following"`
+		FollowsYou   bool          `json:"follows_you"`
+		Goals        []ProfileGoal `json:"goals"`
+		Achievements []Achievement `json:"achievements"`
+	}
+
+	viewerID, user, err := profileUser(c)
+	if user == nil {
+		return err
+	}
+
+	db := database.DB
+	profile := Profile{
+		ProfileSummary: profileSummary(user),
+		IsFollowing:    isFollowing(db, viewerID, user.ID),
+		FollowsYou:     isFollowing(db, user.ID, viewerID),
+		Goals:          []ProfileGoal{},
+	}
+	followers := db.Model(&model.Follow{}).Joins("JOIN users ON users.id = follows.follower_id AND users.deleted_at IS NULL")
+	followers.Where("follows.followee_id = ?", user.ID).Count(&profile.Followers)
+	following := db.Model(&model.Follow{}).Joins("JOIN users ON users.id = follows.followee_id AND users.deleted_at IS NULL")
+	following.Where("follows.follower_id = ?", user.ID).Count(&profile.Following)
+
+	// The profile is the public face of a user, even to themselves
+	visibilities := []string{model.VisibilityPublic}
+	if arePartners(db, user.ID, viewerID) {
+		visibilities = append(visibilities, model.VisibilityPartners)
+	}
+	var goals []model.Goal
+	if err := db.Where("user_id = ? AND group_id IS NULL AND visibility IN ?", user.ID, visibilities).
+		Preload("Subgoals").Preload("Tasks").
+		Order("created_at ASC").Find(&goals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't fetch goals",
+			"errors":  err.Error(),
+		})
+	}
+	for i := range goals {
+		profile.Goals = append(profile.Goals, ProfileGoal{
+			ID:          goals[i].ID,
+			Name:        goals[i].Name,
+			Description: goals[i].Description,
+			Deadline:    goals[i].Deadline,
+			Completed:   goals[i].Completed,
+			Progress:    model.GoalProgress(&goals[i]),
+		})
+	}
+
+	if profile.Achievements, err = achievements(db, user.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't compute achievements",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Profile retrieved successfully",
+		"data":    profile,
+	})
+}
+
+// FollowUser follows another user's profile
+func FollowUser(c *fiber.Ctx) error {
+	userID, user, err := profileUser(c)
+	if user == nil {
+		return err
+	}
+	if user.ID == userID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You can't follow yourself",
+			"data":    nil,
+		})
+	}
+
+	// Following twice is a no-op
+	follow := model.Follow{FollowerID: userID, FolloweeID: user.ID}
+	db := database.DB
+	res := db.Where(follow).FirstOrCreate(&follow)
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't follow user",
+			"errors":  res.Error.Error(),
+		})
+	}
+	if res.RowsAffected > 0 {
+		notify(db, user.ID, model.NotificationFollow, &userID, "", 0, actorName(db, userID)+" started following you")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Now following " + user.Username,
+		"data":    nil,
+	})
+}
+
+// UnfollowUser stops following another user's profile
+func UnfollowUser(c *fiber.Ctx) error {
+	userID, user, err := profileUser(c)
+	if user == nil {
+		return err
+	}
+
+	if err := database.DB.Unscoped().Where("follower_id = ? AND followee_id = ?", userID, user.ID).
+		Delete(&model.Follow{}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't unfollow user",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "No longer following " + user.Username,
+		"data":    nil,
+	})
+}
+
+// GetFollowers lists the users following a profile
+func GetFollowers(c *fiber.Ctx) error {
+	return followList(c, true)
+}
+
+// GetFollowing lists the users a profile follows
+func GetFollowing(c *fiber.Ctx) error {
+	return followList(c, false)
+}
+
+func followList(c *fiber.Ctx, followers bool) error {
+	_, user, err := profileUser(c)
+	if user == nil {
+		return err
+	}
+
+	join, where := "users.id = follows.followee_id", "follows.follower_id = ?"
+	if followers {
+		join, where = "users.id = follows.follower_id", "follows.followee_id = ?"
+	}
+
+	list := []ProfileSummary{}
+	if err := database.DB.Model(&model.Follow{}).
+		Select("users.username, users.name, users.occupation, users.about, users.created_at AS joined_at").
+		Joins("JOIN users ON "+join+" AND users.deleted_at IS NULL").
+		Where(where, user.ID).
+		Order("follows.created_at DESC").
+		Scan(&list).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve users",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Users retrieved successfully",
+		"data":    list,
+	})
+}
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newShareToken returns a random URL-safe token
+func newShareToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// loadSharedGoal loads the goal in the URL if the user owns it. On failure
+// the error response has been written and the returned goal is nil.
+func loadSharedGoal(c *fiber.Ctx) (*model.Goal, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var goal model.Goal
+	if err := database.DB.First(&goal, c.Params("goal_id")).Error; err != nil || goal.GroupID != nil {
+		return nil, c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+	if goal.UserID != userID {
+		return nil, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to share this goal",
+			"data":    nil,
+		})
+	}
+	return &goal, nil
+}
+
+// shareSettings returns a goal's share settings, or the defaults
+func shareSettings(goalID uint) model.ShareSettings {
+	settings := model.DefaultShareSettings(goalID)
+	database.DB.Where("goal_id = ?", goalID).Limit(1).Find(&settings)
+	return settings
+}
+
+// CreateShareLink creates a new share link for a goal
+func CreateShareLink(c *fiber.Ctx) error {
+	type ShareLinkInput struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	var input ShareLinkInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid input",
+				"errors":  err.Error(),
+			})
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The expiry must be in the future",
+			"data":    nil,
+		})
+	}
+
+	goal, err := loadSharedGoal(c)
+	if goal == nil {
+		return err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't generate share token",
+			"errors":  err.Error(),
+		})
+	}
+
+	link := model.ShareLink{
+		GoalID:    goal.ID,
+		UserID:    goal.UserID,
+		Token:     token,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := database.DB.Create(&link).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create share link",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Share link created",
+		"data":    link,
+	})
+}
+
+// GetShareLinks lists a goal's share links along with its share settings
+func GetShareLinks(c *fiber.Ctx) error {
+	goal, err := loadSharedGoal(c)
+	if goal == nil {
+		return err
+	}
+
+	var links []model.ShareLink
+	if err := database.DB.Where("goal_id = ?", goal.ID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve share links",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Share links retrieved successfully",
+		"data": fiber.Map{
+			"links":    links,
+			"settings": shareSettings(goal.ID),
+		},
+	})
+}
+
+// RevokeShareLink stops a share link from working
+func RevokeShareLink(c *fiber.Ctx) error {
+	goal, err := loadSharedGoal(c)
+	if goal == nil {
+		return err
+	}
+
+	db := database.DB
+	var link model.ShareLink
+	if err := db.Where("id = ? AND goal_id = ?", c.Params("share_id"), goal.ID).First(&link).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Share link not found",
+			"data":    nil,
+		})
+	}
+
+	if link.RevokedAt == nil {
+		now := time.Now()
+		link.RevokedAt = &now
+		if err := db.Save(&link).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't revoke share link",
+				"errors":  err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Share link revoked",
+		"data":    link,
+	})
+}
+
+// UpdateShareSettings sets which fields a goal's share links expose
+func UpdateShareSettings(c *fiber.Ctx) error {
+	type ShareSettingsInput struct {
+		ShowDescription *bool `json:"show_description" validate:"required"`
+		ShowDeadline    *bool `json:"show_deadline" validate:"required"`
+		ShowProgress    *bool `json:"show_progress" validate:"required"`
+		ShowSubgoals    *bool `json:"show_subgoals" validate:"required"`
+		ShowHabits      *bool `json:"show_habits" validate:"required"`
+	}
+
+	var input ShareSettingsInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	goal, err := loadSharedGoal(c)
+	if goal == nil {
+		return err
+	}
+
+	settings := shareSettings(goal.ID)
+	settings.ShowDescription = *input.ShowDescription
+	settings.ShowDeadline = *input.ShowDeadline
+	settings.ShowProgress = *input.ShowProgress
+	settings.ShowSubgoals = *input.ShowSubgoals
+	settings.ShowHabits = *input.ShowHabits
+	if err := database.DB.Save(&settings).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update share settings",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Share settings updated",
+		"data":    settings,
+	})
+}
+
+// GetSharedGoal returns the read-only view of a goal behind a share link.
+// It needs no account, so it only exposes what the owner chose to share and
+// never user details or internal IDs.
+func GetSharedGoal(c *fiber.Ctx) error {
+	type PublicSubgoal struct {
+		Name      string `json:"name"`
+		Completed bool   `json:"completed"`
+	}
+	type PublicHabit struct {
+		Name          string `json:"name"`
+		Frequency     string `json:"frequency"`
+		CurrentStreak int    `json:"current_streak"`
+	}
+	type PublicGoal struct {
+		Name        string          `json:"name"`
+		Completed   bool            `json:"completed"`
+		Description string          `json:"description,omitempty"`
+		Deadline    *time.Time      `json:"deadline,omitempty"`
+		Progress    *float64        `json:"progress,omitempty"`
+		Subgoals    []PublicSubgoal `json:"subgoals,omitempty"`
+		Habits      []PublicHabit   `json:"habits,omitempty"`
+	}
+
+	notFound := func() error {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This link is invalid or has expired",
+			"data":    nil,
+		})
+	}
+
+	db := database.DB
+	var link model.ShareLink
+	if err := db.Where("token = ?", c.Params("token")).First(&link).Error; err != nil {
+		return notFound()
+	}
+	if link.RevokedAt != nil || (link.ExpiresAt != nil && link.ExpiresAt.Before(time.Now())) {
+		return notFound()
+	}
+
+	var goal model.Goal
+	if err := db.Preload("Subgoals").Preload("Habits").Preload("Tasks").First(&goal, link.GoalID).Error; err != nil {
+		return notFound()
+	}
+
+	settings := shareSettings(goal.ID)
+	view := PublicGoal{Name: goal.Name, Completed: goal.Completed}
+	if settings.ShowDescription {
+		view.Description = goal.Description
+	}
+	if settings.ShowDeadline {
+		view.Deadline = &goal.Deadline
+	}
+	if settings.ShowProgress {
+		progress := model.GoalProgress(&goal)
+		view.Progress = &progress
+	}
+	if settings.ShowSubgoals {
+		for _, subgoal := range goal.Subgoals {
+			view.Subgoals = append(view.Subgoals, PublicSubgoal{Name: subgoal.Name, Completed: subgoal.Completed})
+		}
+	}
+	if settings.ShowHabits {
+		goals := []model.Goal{goal}
+		if err := database.FillHabitStreaks(db, goals); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Couldn't fetch habit streaks",
+				"errors":  err.Error(),
+			})
+		}
+		for _, habit := range goals[0].Habits {
+			view.Habits = append(view.Habits, PublicHabit{Name: habit.Name, Frequency: habit.Frequency, CurrentStreak: habit.CurrentStreak})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Goal retrieved successfully",
+		"data":    view,
+	})
+}
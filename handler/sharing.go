@@ -0,0 +1,463 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var roleRank = map[string]int{
+	model.RoleViewer: 1,
+	model.RoleEditor: 2,
+	model.RoleOwner:  3,
+}
+
+// listRole returns the user's role on a list, or "" if they have no access.
+// The user who created the list is always an owner.
+func listRole(db *gorm.DB, list *model.TaskList, userID uint) string {
+	if list.UserID == userID {
+		return model.RoleOwner
+	}
+	var member model.ListMember
+	if err := db.Where("task_list_id = ? AND user_id = ?", list.ID, userID).Limit(1).Find(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// hasRole reports whether role grants at least the permissions of min
+func hasRole(role, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
+
+// notifyListMembers tells everyone with access to a shared list, except the
+// user who made it, about a change to it
+func notifyListMembers(db *gorm.DB, list *model.TaskList, actorID uint, resourceType string, resourceID uint, message string) {
+	var memberIDs []uint
+	db.Model(&model.ListMember{}).Where("task_list_id = ? AND user_id <> ?", list.ID, list.UserID).Pluck("user_id", &memberIDs)
+	for _, id := range append(memberIDs, list.UserID) {
+		notify(db, id, model.NotificationListChange, &actorID, resourceType, resourceID, message)
+	}
+}
+
+// InviteToList invites another user, by username or email, to a list
+func InviteToList(c *fiber.Ctx) error {
+	type InviteInput struct {
+		Username string `json:"username"`
+		Email    string `json:"email" validate:"omitempty,email"`
+		Role     string `json:"role" validate:"required,oneof=owner editor viewer"`
+	}
+
+	var input InviteInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Validate input
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var list model.TaskList
+	if err := db.First(&list, "id = ?", c.Params("list_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "List not found",
+			"errors":  err.Error(),
+		})
+	}
+
+	if !hasRole(listRole(db, &list, userID), model.RoleOwner) {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only owners can invite people to this list",
+			"data":    nil,
+		})
+	}
+
+	// Look up the invitee
+	var invitee *model.User
+	var err error
+	switch {
+	case input.Username != "":
+		invitee, err = getUserByUsername(input.Username)
+	case input.Email != "":
+		invitee, err = getUserByEmail(input.Email)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Either a username or an email is required",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+			"errors":  err.Error(),
+		})
+	}
+	// Users who blocked each other look the same as unknown ones
+	if invitee == nil || isBlocked(db, userID, invitee.ID) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+			"data":    nil,
+		})
+	}
+
+	if listRole(db, &list, invitee.ID) != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User already has access to this list",
+			"data":    nil,
+		})
+	}
+
+	var pending int64
+	db.Model(&model.ListInvitation{}).
+		Where("task_list_id = ? AND invitee_id = ? AND status = ?", list.ID, invitee.ID, model.InvitationPending).
+		Count(&pending)
+	if pending > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User has already been invited to this list",
+			"data":    nil,
+		})
+	}
+
+	invitation := model.ListInvitation{
+		TaskListID: list.ID,
+		InviterID:  userID,
+		InviteeID:  invitee.ID,
+		Role:       input.Role,
+		Status:     model.InvitationPending,
+	}
+	if err := db.Create(&invitation).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create invitation",
+			"errors":  err.Error(),
+		})
+	}
+
+	notify(db, invitee.ID, model.NotificationListInvitation, &userID, model.ResourceList, list.ID, actorName(db, userID)+" invited you to the list \""+list.Name+"\"")
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation sent",
+		"data":    invitation,
+	})
+}
+
+// GetListInvitations lists the user's pending invitations
+func GetListInvitations(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var invitations []model.ListInvitation
+	if err := db.Preload("TaskList").
+		Where("invitee_id = ? AND status = ?", userID, model.InvitationPending).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve invitations",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitations retrieved successfully",
+		"data":    invitations,
+	})
+}
+
+// AcceptListInvitation joins the list with the invited role
+func AcceptListInvitation(c *fiber.Ctx) error {
+	return respondToInvitation(c, true)
+}
+
+// DeclineListInvitation turns an invitation down
+func DeclineListInvitation(c *fiber.Ctx) error {
+	return respondToInvitation(c, false)
+}
+
+func respondToInvitation(c *fiber.Ctx, accept bool) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var invitation model.ListInvitation
+	if err := db.First(&invitation, "id = ?", c.Params("invitation_id")).Error; err != nil ||
+		invitation.InviteeID != userID || invitation.Status != model.InvitationPending {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invitation not found",
+			"data":    nil,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if !accept {
+			return tx.Model(&invitation).Update("status", model.InvitationDeclined).Error
+		}
+
+		var list model.TaskList
+		if err := tx.First(&list, "id = ?", invitation.TaskListID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&invitation).Update("status", model.InvitationAccepted).Error; err != nil {
+			return err
+		}
+		if listRole(tx, &list, userID) != "" {
+			return nil
+		}
+		return tx.Create(&model.ListMember{
+			TaskListID: list.ID,
+			UserID:     userID,
+			Role:       invitation.Role,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "List not found",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't respond to invitation",
+			"errors":  err.Error(),
+		})
+	}
+
+	message := "Invitation declined"
+	if accept {
+		message = "Invitation accepted"
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    invitation,
+	})
+}
+
+// GetListMembers lists everyone with access to a list
+func GetListMembers(c *fiber.Ctx) error {
+	type MemberOutput struct {
+		UserID   uint   `json:"user_id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+		Role     string `json:"role"`
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var list model.TaskList
+	if err := db.First(&list, "id = ?", c.Params("list_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "List not found",
+			"errors":  err.Error(),
+		})
+	}
+
+	if listRole(db, &list, userID) == "" {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to view this list",
+			"data":    nil,
+		})
+	}
+
+	var members []MemberOutput
+	if err := db.Model(&model.User{}).
+		Select("users.id AS user_id, users.username, users.name, ? AS role", model.RoleOwner).
+		Where("users.id = ?", list.UserID).
+		Scan(&members).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve members",
+			"errors":  err.Error(),
+		})
+	}
+
+	var shared []MemberOutput
+	if err := db.Model(&model.ListMember{}).
+		Select("users.id AS user_id, users.username, users.name, list_members.role").
+		Joins("JOIN users ON users.id = list_members.user_id AND users.deleted_at IS NULL").
+		Where("list_members.task_list_id = ?", list.ID).
+		Order("list_members.created_at ASC").
+		Scan(&shared).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve members",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Members retrieved successfully",
+		"data":    append(members, shared...),
+	})
+}
+
+// UpdateListMember changes a member's role
+func UpdateListMember(c *fiber.Ctx) error {
+	type UpdateMemberInput struct {
+		Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+	}
+
+	var input UpdateMemberInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	list, member, status, message := loadListMember(c)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"status": "error", "message": message, "data": nil})
+	}
+
+	userID := c.Locals("userID").(uint)
+	if !hasRole(listRole(database.DB, list, userID), model.RoleOwner) {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only owners can change member roles",
+			"data":    nil,
+		})
+	}
+
+	if err := database.DB.Model(member).Update("role", input.Role).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update member",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member role updated",
+		"data":    member,
+	})
+}
+
+// RemoveListMember removes a member from a list. Members may remove
+// themselves; removing anyone else requires the owner role.
+func RemoveListMember(c *fiber.Ctx) error {
+	list, member, status, message := loadListMember(c)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"status": "error", "message": message, "data": nil})
+	}
+
+	userID := c.Locals("userID").(uint)
+	if member.UserID != userID && !hasRole(listRole(database.DB, list, userID), model.RoleOwner) {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only owners can remove other members",
+			"data":    nil,
+		})
+	}
+
+	if err := database.DB.Unscoped().Delete(member).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't remove member",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member removed",
+		"data":    nil,
+	})
+}
+
+// loadListMember resolves the list and membership addressed by the route,
+// returning a non-zero status and message when that fails
+func loadListMember(c *fiber.Ctx) (*model.TaskList, *model.ListMember, int, string) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return nil, nil, 500, "Failed to retrieve user ID"
+	}
+
+	memberID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return nil, nil, fiber.StatusBadRequest, "Invalid user ID format"
+	}
+
+	db := database.DB
+	var list model.TaskList
+	if err := db.First(&list, "id = ?", c.Params("list_id")).Error; err != nil {
+		return nil, nil, 404, "List not found"
+	}
+	if listRole(db, &list, userID) == "" {
+		return nil, nil, 403, "You are not authorized to view this list"
+	}
+
+	var member model.ListMember
+	if err := db.Where("task_list_id = ? AND user_id = ?", list.ID, uint(memberID)).First(&member).Error; err != nil {
+		return nil, nil, 404, "Member not found"
+	}
+	return &list, &member, 0, ""
+}
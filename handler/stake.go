@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"app/database"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateStake attaches a stake and a referee to one of the user's goals
+func CreateStake(c *fiber.Ctx) error {
+	type StakeInput struct {
+		Amount  float64 `json:"amount" validate:"required,gt=0"`
+		Unit    string  `json:"unit" validate:"omitempty,max=20"`
+		Referee string  `json:"referee" validate:"required"` // Username
+	}
+
+	var input StakeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var goal model.Goal
+	if err := db.First(&goal, c.Params("goal_id")).Error; err != nil || goal.GroupID != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Goal not found",
+		})
+	}
+	if goal.UserID != userID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to stake this goal",
+			"data":    nil,
+		})
+	}
+	if goal.Completed || !goal.Deadline.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Stakes can only be attached to open goals with a future deadline",
+			"data":    nil,
+		})
+	}
+
+	referee, err := getUserByUsername(input.Referee)
+	if err != nil || referee == nil || referee.ID == userID || isBlocked(db, userID, referee.ID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Referee must be another user",
+			"data":    nil,
+		})
+	}
+
+	var open int64
+	db.Model(&model.Stake{}).Where("goal_id = ? AND status IN ?", goal.ID,
+		[]string{model.StakeActive, model.StakeClaimed}).Count(&open)
+	if open > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This goal already has a stake",
+			"data":    nil,
+		})
+	}
+
+	stake := model.Stake{
+		GoalID:    goal.ID,
+		UserID:    userID,
+		RefereeID: referee.ID,
+		Amount:    input.Amount,
+		Unit:      input.Unit,
+		Status:    model.StakeActive,
+	}
+	if stake.Unit == "" {
+		stake.Unit = "points"
+	}
+	if err := db.Create(&stake).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create stake",
+			"errors":  err.Error(),
+		})
+	}
+
+	notify(db, referee.ID, model.NotificationStake, &userID, model.ResourceGoal, goal.ID, actorName(db, userID)+" asked you to referee their stake on \""+goal.Name+"\"")
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Stake created",
+		"data":    stake,
+	})
+}
+
+// GetStakes lists the user's own stakes and the ones they referee
+func GetStakes(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var staked, refereeing []model.Stake
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&staked).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve stakes",
+			"errors":  err.Error(),
+		})
+	}
+	if err := db.Where("referee_id = ?", userID).Order("created_at DESC").Find(&refereeing).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve stakes",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Stakes retrieved successfully",
+		"data": fiber.Map{
+			"staked":     staked,
+			"refereeing": refereeing,
+		},
+	})
+}
+
+// ApproveStake lets the referee accept a claimed completion
+func ApproveStake(c *fiber.Ctx) error {
+	return refereeAction(c, true)
+}
+
+// RejectStake lets the referee reject a claimed completion within the
+// dispute window, forfeiting the stake
+func RejectStake(c *fiber.Ctx) error {
+	return refereeAction(c, false)
+}
+
+func refereeAction(c *fiber.Ctx, approve bool) error {
+	type RefereeInput struct {
+		Comment string `json:"comment"`
+	}
+
+	var input RefereeInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid input",
+				"errors":  err.Error(),
+			})
+		}
+	}
+	if !approve && input.Comment == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A comment is required to reject a claim",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var stake model.Stake
+	if err := db.First(&stake, c.Params("stake_id")).Error; err != nil || stake.RefereeID != userID {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Stake not found",
+			"data":    nil,
+		})
+	}
+
+	// Check the claim on a locked row, so that settling the stake or the owner
+	// reopening the goal can't change it in the meantime
+	now := time.Now()
+	errNoClaim := errors.New("no open claim")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stake, stake.ID).Error; err != nil {
+			return err
+		}
+		if stake.Status != model.StakeClaimed || (stake.DisputeUntil != nil && now.After(*stake.DisputeUntil)) {
+			return errNoClaim
+		}
+
+		stake.RefereeComment = input.Comment
+		if approve {
+			stake.Status = model.StakeKept
+			stake.ResolvedAt = &now
+			return tx.Save(&stake).Error
+		}
+		var goal model.Goal
+		if err := tx.Unscoped().First(&goal, stake.GoalID).Error; err != nil {
+			return err
+		}
+		return database.ForfeitStake(tx, &stake, &goal, model.ForfeitRefereeRejected, now)
+	})
+	if errors.Is(err, errNoClaim) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "There is no open claim on this stake",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't review claim",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Forfeiting already told the owner
+	if approve {
+		notify(db, stake.UserID, model.NotificationStake, &userID, model.ResourceGoal, stake.GoalID, actorName(db, userID)+" approved your completion claim")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Claim reviewed",
+		"data":    stake,
+	})
+}
+
+// GetLedger returns the user's forfeited stakes, newest first, with totals
+// per unit
+func GetLedger(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var entries []model.LedgerEntry
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&entries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve ledger",
+			"errors":  err.Error(),
+		})
+	}
+
+	totals := make(map[string]float64)
+	for _, entry := range entries {
+		totals[entry.Unit] += entry.Amount
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Ledger retrieved successfully",
+		"data": fiber.Map{
+			"entries": entries,
+			"totals":  totals,
+		},
+	})
+}
+
+// updateStakeClaim claims the goal's active stake when it is completed and
+// withdraws a pending claim when it is reopened. Completing a goal after its
+// deadline forfeits the stake right away, so it can't be claimed before
+// SettleStakes gets to it.
+func updateStakeClaim(tx *gorm.DB, goal *model.Goal) error {
+	var stake model.Stake
+	from, to := model.StakeActive, model.StakeClaimed
+	if !goal.Completed {
+		from, to = to, from
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("goal_id = ? AND status = ?", goal.ID, from).First(&stake).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if to == model.StakeClaimed {
+		now := time.Now()
+		if !now.Before(goal.Deadline) {
+			return database.ForfeitStake(tx, &stake, goal, model.ForfeitDeadlineMissed, now)
+		}
+		if err := database.ClaimStake(tx, &stake, now); err != nil {
+			return err
+		}
+		_, err := database.Notify(tx, stake.RefereeID, model.NotificationStake, &goal.UserID, model.ResourceGoal, goal.ID,
+			actorName(tx, goal.UserID)+" claims to have completed \""+goal.Name+"\", please review it")
+		return err
+	}
+	return tx.Model(&stake).Updates(map[string]interface{}{
+		"status": to, "claimed_at": nil, "dispute_until": nil,
+	}).Error
+}
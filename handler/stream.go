@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"app/model"
+	"app/pubsub"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"gorm.io/gorm"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment to keep
+// proxies from closing it
+const streamHeartbeat = 25 * time.Second
+
+// listAudience returns the users who can see a list: its owner and members
+func listAudience(db *gorm.DB, listID uint) []uint {
+	var list model.TaskList
+	if err := db.Unscoped().First(&list, listID).Error; err != nil {
+		return nil
+	}
+	var ids []uint
+	db.Model(&model.ListMember{}).Where("task_list_id = ? AND user_id <> ?", list.ID, list.UserID).Pluck("user_id", &ids)
+	return append(ids, list.UserID)
+}
+
+// goalAudience returns the users who follow a goal live: its owner, the
+// members of its group and, unless it is private, the owner's partners
+func goalAudience(db *gorm.DB, goalID uint) []uint {
+	var goal model.Goal
+	if err := db.Unscoped().First(&goal, goalID).Error; err != nil {
+		return nil
+	}
+	var ids []uint
+	if goal.GroupID != nil {
+		db.Model(&model.GroupMember{}).Where("group_id = ? AND user_id <> ?", *goal.GroupID, goal.UserID).Pluck("user_id", &ids)
+	} else if goal.Visibility != model.VisibilityPrivate {
+		db.Model(&model.Partnership{}).
+			Select("CASE WHEN requester_id = ? THEN addressee_id ELSE requester_id END", goal.UserID).
+			Where("(requester_id = ? OR addressee_id = ?) AND status = ?", goal.UserID, goal.UserID, model.PartnershipAccepted).
+			Pluck("user_id", &ids)
+	}
+	return append(ids, goal.UserID)
+}
+
+// resourceAudience returns the users who can see a goal, subgoal, habit,
+// list or task
+func resourceAudience(db *gorm.DB, resourceType string, resourceID uint) []uint {
+	switch resourceType {
+	case model.ResourceGoal:
+		return goalAudience(db, resourceID)
+	case model.ResourceSubgoal:
+		var subgoal model.Subgoal
+		if db.Unscoped().First(&subgoal, resourceID).Error == nil {
+			return goalAudience(db, subgoal.GoalID)
+		}
+	case model.ResourceHabit:
+		var habit model.Habit
+		if db.Unscoped().First(&habit, resourceID).Error == nil {
+			return goalAudience(db, habit.GoalID)
+		}
+	case model.ResourceList:
+		return listAudience(db, resourceID)
+	case model.ResourceTask:
+		var task model.Task
+		if db.Unscoped().First(&task, resourceID).Error == nil {
+			return listAudience(db, task.TaskListID)
+		}
+	}
+	return nil
+}
+
+// publish pushes a change event to everyone who can see the resource
+func publish(db *gorm.DB, eventType string, actorID uint, resourceType string, resourceID uint, data interface{}) error {
+	event := pubsub.Event{
+		Type:         eventType,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ActorID:      actorID,
+		UserIDs:      resourceAudience(db, resourceType, resourceID),
+	}
+	if data != nil {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		event.Data = payload
+	}
+	return pubsub.Publish(event)
+}
+
+// Stream sends the caller change events for resources they can see as
+// Server-Sent Events until the client disconnects
+func Stream(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	events, unsubscribe := pubsub.Subscribe()
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 5000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if !event.For(userID) {
+					continue
+				}
+				event.UserIDs = nil
+				payload, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			// Writing fails once the client has gone away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+	return nil
+}
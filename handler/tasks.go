@@ -3,9 +3,11 @@ package handler
 import (
 	"app/database"
 	"app/model"
+	"errors"
 	"fmt"
 	"gorm.io/gorm"
 	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -50,7 +52,12 @@ func CreateList(c *fiber.Ctx) error {
 		Name:   input.Name,
 	}
 
-	if err := db.Create(&list).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&list).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceList, list.ID, nil, model.ActivityCreated, list.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't create list",
@@ -100,7 +107,7 @@ func UpdateListName(c *fiber.Ctx) error {
 		})
 	}
 
-	// Retrieve the list and ensure it belongs to the logged-in user
+	// Retrieve the list and ensure the logged-in user owns it
 	db := database.DB
 	var list model.TaskList
 	if err := db.First(&list, "id = ?", listID).Error; err != nil {
@@ -111,7 +118,7 @@ func UpdateListName(c *fiber.Ctx) error {
 		})
 	}
 
-	if list.UserID != uint(userID) {
+	if !hasRole(listRole(db, &list, userID), model.RoleOwner) {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
 			"message": "You are not authorized to update this list",
@@ -121,7 +128,12 @@ func UpdateListName(c *fiber.Ctx) error {
 
 	// Update the list name
 	list.Name = input.Name
-	if err := db.Save(&list).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&list).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceList, list.ID, nil, model.ActivityUpdated, list.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't update list name",
@@ -138,7 +150,11 @@ func UpdateListName(c *fiber.Ctx) error {
 
 func AddTaskToList(c *fiber.Ctx) error {
 	type AddTaskInput struct {
-		Text string `json:"text" validate:"required,min=1"`
+		Text      string     `json:"text" validate:"required,min=1"`
+		GoalID    *uint      `json:"goal_id"`
+		SubgoalID *uint      `json:"subgoal_id"`
+		DueDate   *time.Time `json:"due_date"`
+		ParentID  *uint      `json:"parent_id"` // Makes the task a subtask of a task in the same list
 	}
 
 	var input AddTaskInput
@@ -182,8 +198,8 @@ func AddTaskToList(c *fiber.Ctx) error {
 		})
 	}
 
-	//Verify the list belongs to the authenticated user
-	if list.UserID != uint(userID) {
+	//Verify the authenticated user may edit the list
+	if !hasRole(listRole(db, &list, userID), model.RoleEditor) {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
 			"message": "You are not authorized to add tasks to this list",
@@ -191,13 +207,42 @@ func AddTaskToList(c *fiber.Ctx) error {
 		})
 	}
 
+	// Make sure the goal the task moves forward (if any) is the user's own
+	if err := checkGoalLink(db, userID, input.GoalID, input.SubgoalID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	if input.ParentID != nil {
+		var parent model.Task
+		if err := db.Where("id = ? AND task_list_id = ?", *input.ParentID, list.ID).First(&parent).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "The parent task must be in the same list",
+				"data":    nil,
+			})
+		}
+	}
+
 	// Create the task for the specified list
 	task := model.Task{
 		TaskListID: list.ID,
 		Text:       input.Text,
-	}
-
-	if err := db.Create(&task).Error; err != nil {
+		GoalID:     input.GoalID,
+		SubgoalID:  input.SubgoalID,
+		DueDate:    input.DueDate,
+		ParentID:   input.ParentID,
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceTask, task.ID, nil, model.ActivityCreated, task.Text)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't create task",
@@ -205,6 +250,8 @@ func AddTaskToList(c *fiber.Ctx) error {
 		})
 	}
 
+	notifyListMembers(db, &list, userID, model.ResourceTask, task.ID, actorName(db, userID)+" added \""+task.Text+"\" to \""+list.Name+"\"")
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Task added successfully",
@@ -221,12 +268,15 @@ func GetListsForUser(c *fiber.Ctx) error {
 		})
 	}
 
-	// Retrieve all lists for the user, including their tasks
+	// Retrieve all lists the user owns or is a member of, including their tasks
 	db := database.DB
 	var lists []model.TaskList
 	if err := db.Preload("Tasks", func(db *gorm.DB) *gorm.DB {
 		return db.Order("created_at ASC") // Orders tasks by ID in ascending order
-	}).Where("user_id = ?", uint(userID)).Find(&lists).Error; err != nil {
+	}).Preload("Tasks.Labels").
+		Where("user_id = ? OR id IN (?)", userID, db.Model(&model.ListMember{}).Select("task_list_id").Where("user_id = ?", userID)).
+		Order("created_at ASC").
+		Find(&lists).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't retrieve lists",
@@ -234,6 +284,11 @@ func GetListsForUser(c *fiber.Ctx) error {
 		})
 	}
 
+	for i := range lists {
+		lists[i].Role = listRole(db, &lists[i], userID)
+	}
+	fillListCommentCounts(db, lists)
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Lists retrieved successfully",
@@ -275,7 +330,7 @@ func DeleteList(c *fiber.Ctx) error {
 	db := database.DB
 	var list model.TaskList
 
-	// Check if the list exists and belongs to the user
+	// Check if the list exists and the user owns it
 	if err := db.First(&list, "id = ?", uint(id)).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"status":  "error",
@@ -284,7 +339,7 @@ func DeleteList(c *fiber.Ctx) error {
 		})
 	}
 
-	if list.UserID != uint(userID) {
+	if !hasRole(listRole(db, &list, userID), model.RoleOwner) {
 		return c.Status(403).JSON(fiber.Map{
 			"status":  "error",
 			"message": "You are not authorized to delete this task list",
@@ -292,8 +347,13 @@ func DeleteList(c *fiber.Ctx) error {
 		})
 	}
 
-	// Delete the task list
-	if err := db.Delete(&list).Error; err != nil {
+	// Delete the task list together with its tasks
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := database.DeleteTaskList(tx, &list, time.Now()); err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceList, list.ID, nil, model.ActivityDeleted, list.Name)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't delete task list",
@@ -359,8 +419,8 @@ func DeleteTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Ensure the task belongs to a list owned by the logged-in user
-	if taskList.UserID != uint(userID) {
+	// Ensure the logged-in user may edit the task's list
+	if !hasRole(listRole(db, &taskList, userID), model.RoleEditor) {
 		return c.Status(403).JSON(fiber.Map{
 			"status":  "error",
 			"message": "You are not authorized to delete this task",
@@ -369,7 +429,12 @@ func DeleteTask(c *fiber.Ctx) error {
 	}
 
 	// Delete the task
-	if err := db.Delete(&task).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := database.DeleteTask(tx, &task, time.Now()); err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceTask, task.ID, nil, model.ActivityDeleted, task.Text)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't delete task",
@@ -377,6 +442,8 @@ func DeleteTask(c *fiber.Ctx) error {
 		})
 	}
 
+	notifyListMembers(db, &taskList, userID, model.ResourceList, taskList.ID, actorName(db, userID)+" deleted \""+task.Text+"\" from \""+taskList.Name+"\"")
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Task successfully deleted",
@@ -435,8 +502,8 @@ func ToggleTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Ensure the task belongs to a list owned by the logged-in user
-	if taskList.UserID != uint(userID) {
+	// Ensure the logged-in user may edit the task's list
+	if !hasRole(listRole(db, &taskList, userID), model.RoleEditor) {
 		return c.Status(403).JSON(fiber.Map{
 			"status":  "error",
 			"message": "You are not authorized to update this task",
@@ -444,9 +511,22 @@ func ToggleTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Toggle the task's completed status
+	// Toggle the task's completed status, recording who completed it
 	task.Completed = !task.Completed
-	if err := db.Save(&task).Error; err != nil {
+	if task.Completed {
+		now := time.Now()
+		task.CompletedByID = &userID
+		task.CompletedAt = &now
+	} else {
+		task.CompletedByID = nil
+		task.CompletedAt = nil
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceTask, task.ID, nil, completionAction(task.Completed), task.Text)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't update task",
@@ -454,6 +534,8 @@ func ToggleTask(c *fiber.Ctx) error {
 		})
 	}
 
+	notifyListMembers(db, &taskList, userID, model.ResourceTask, task.ID, actorName(db, userID)+" "+completionAction(task.Completed)+" \""+task.Text+"\" in \""+taskList.Name+"\"")
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Task marked as completed",
@@ -535,8 +617,8 @@ func UpdateTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Ensure the task belongs to a list owned by the logged-in user
-	if taskList.UserID != uint(userID) {
+	// Ensure the logged-in user may edit the task's list
+	if !hasRole(listRole(db, &taskList, userID), model.RoleEditor) {
 		return c.Status(403).JSON(fiber.Map{
 			"status":  "error",
 			"message": "You are not authorized to update this task",
@@ -546,7 +628,12 @@ func UpdateTask(c *fiber.Ctx) error {
 
 	// Update the task name
 	task.Text = input.Text
-	if err := db.Save(&task).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, model.ResourceTask, task.ID, nil, model.ActivityUpdated, task.Text)
+	}); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Couldn't update task",
@@ -560,3 +647,150 @@ func UpdateTask(c *fiber.Ctx) error {
 		"data":    task,
 	})
 }
+
+func LinkTaskToGoal(c *fiber.Ctx) error {
+	// A null goal_id unlinks the task
+	type LinkTaskInput struct {
+		GoalID    *uint `json:"goal_id"`
+		SubgoalID *uint `json:"subgoal_id"`
+	}
+
+	var input LinkTaskInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Get the task ID from URL parameters
+	taskIDStr := c.Params("task_id")
+	taskID, err := strconv.ParseUint(taskIDStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid task ID format",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	var task model.Task
+
+	// Check if the task exists
+	if err := db.First(&task, "id = ?", uint(taskID)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No task found with the provided ID",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Retrieve the associated task list
+	var taskList model.TaskList
+	if err := db.First(&taskList, "id = ?", task.TaskListID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Task list not found",
+			"errors":  err.Error(),
+		})
+	}
+
+	// Ensure the logged-in user may edit the task's list
+	if !hasRole(listRole(db, &taskList, userID), model.RoleEditor) {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You are not authorized to update this task",
+			"data":    nil,
+		})
+	}
+
+	if err := checkGoalLink(db, userID, input.GoalID, input.SubgoalID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	task.GoalID = input.GoalID
+	task.SubgoalID = input.SubgoalID
+	if err := db.Save(&task).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update task",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Task goal link updated successfully",
+		"data":    task,
+	})
+}
+
+// GetUnalignedTasks lists the user's tasks that don't move any goal forward
+func GetUnalignedTasks(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	// Tasks linked to a goal that has since been deleted count as unaligned too
+	db := database.DB
+	var tasks []model.Task
+	if err := db.
+		Where("task_list_id IN (?)", db.Model(&model.TaskList{}).Select("id").Where("user_id = ?", userID)).
+		Where("goal_id IS NULL OR goal_id NOT IN (?)", db.Model(&model.Goal{}).Select("id")).
+		Order("created_at ASC").
+		Find(&tasks).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve tasks",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Unaligned tasks retrieved successfully",
+		"data":    tasks,
+	})
+}
+
+// checkGoalLink verifies that a task may be linked to the given goal and
+// subgoal: the goal must belong to the user and the subgoal to the goal
+func checkGoalLink(db *gorm.DB, userID uint, goalID, subgoalID *uint) error {
+	if goalID == nil {
+		if subgoalID != nil {
+			return errors.New("A subgoal can only be set together with its goal")
+		}
+		return nil
+	}
+
+	var goal model.Goal
+	if err := db.First(&goal, "id = ?", *goalID).Error; err != nil || goal.UserID != userID {
+		return errors.New("Goal not found")
+	}
+
+	if subgoalID != nil {
+		var subgoal model.Subgoal
+		if err := db.Where("goal_id = ? AND id = ?", goal.ID, *subgoalID).First(&subgoal).Error; err != nil {
+			return errors.New("Subgoal not found")
+		}
+	}
+	return nil
+}
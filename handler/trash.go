@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"strconv"
+
+	"app/database"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetTrash lists the user's soft-deleted goals, task lists and tasks
+func GetTrash(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+
+	var goals []model.Goal
+	if err := db.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").Find(&goals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve trash",
+			"errors":  err.Error(),
+		})
+	}
+
+	var lists []model.TaskList
+	if err := db.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").Find(&lists).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve trash",
+			"errors":  err.Error(),
+		})
+	}
+
+	var tasks []model.Task
+	if err := db.Unscoped().
+		Where("task_list_id IN (?)", db.Unscoped().Model(&model.TaskList{}).Select("id").Where("user_id = ?", userID)).
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").Find(&tasks).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve trash",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Trash retrieved successfully",
+		"data": fiber.Map{
+			"goals": goals,
+			"lists": lists,
+			"tasks": tasks,
+		},
+	})
+}
+
+// RestoreFromTrash undeletes a goal, list or task, including the children
+// that were deleted together with it
+func RestoreFromTrash(c *fiber.Ctx) error {
+	return trashAction(c, false)
+}
+
+// DeleteFromTrash permanently deletes a goal, list or task from the trash
+func DeleteFromTrash(c *fiber.Ctx) error {
+	return trashAction(c, true)
+}
+
+func trashAction(c *fiber.Ctx, purge bool) error {
+	kind := c.Params("type")
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid ID format",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	db := database.DB
+	notFound := func() error {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No deleted " + kind + " found with the provided ID",
+			"data":    nil,
+		})
+	}
+
+	var action func(tx *gorm.DB) error
+	switch kind {
+	case "goal":
+		var goal model.Goal
+		if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&goal, uint(id)).Error; err != nil || goal.UserID != userID {
+			return notFound()
+		}
+		action = func(tx *gorm.DB) error {
+			if purge {
+				return database.PurgeGoal(tx, goal.ID)
+			}
+			return database.RestoreGoal(tx, &goal)
+		}
+	case "list":
+		var list model.TaskList
+		if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&list, uint(id)).Error; err != nil || list.UserID != userID {
+			return notFound()
+		}
+		action = func(tx *gorm.DB) error {
+			if purge {
+				return database.PurgeTaskList(tx, list.ID)
+			}
+			return database.RestoreTaskList(tx, &list)
+		}
+	case "task":
+		var task model.Task
+		if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&task, uint(id)).Error; err != nil {
+			return notFound()
+		}
+		var list model.TaskList
+		if err := db.Unscoped().First(&list, task.TaskListID).Error; err != nil || list.UserID != userID {
+			return notFound()
+		}
+		if !purge && list.DeletedAt.Valid {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "The task's list is in the trash, restore the list first",
+				"data":    nil,
+			})
+		}
+		if !purge && task.ParentID != nil {
+			var parent model.Task
+			if err := db.Unscoped().First(&parent, *task.ParentID).Error; err == nil && parent.DeletedAt.Valid {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"status":  "error",
+					"message": "The task's parent is in the trash, restore it first",
+					"data":    nil,
+				})
+			}
+		}
+		action = func(tx *gorm.DB) error {
+			if purge {
+				return database.PurgeTask(tx, task.ID)
+			}
+			return database.RestoreTask(tx, &task)
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Type must be one of goal, list or task",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Transaction(action); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update trash",
+			"errors":  err.Error(),
+		})
+	}
+
+	message := "Restored successfully"
+	if purge {
+		message = "Permanently deleted"
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    nil,
+	})
+}
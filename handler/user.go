@@ -2,6 +2,7 @@ package handler
 
 import (
 	"strconv"
+	"time"
 
 	"app/database"
 	"app/model"
@@ -10,6 +11,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func hashPassword(password string) (string, error) {
@@ -42,7 +44,30 @@ func validUser(id string, p string) bool {
 	return true
 }
 
-// GetUser get a user
+// Account is a user as shown to themselves
+type Account struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	Occupation string    `json:"occupation"`
+	About      string    `json:"about"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func account(user *model.User) Account {
+	return Account{
+		ID:         user.ID,
+		Name:       user.Name,
+		Username:   user.Username,
+		Email:      user.Email,
+		Occupation: user.Occupation,
+		About:      user.About,
+		CreatedAt:  user.CreatedAt,
+	}
+}
+
+// GetUser get a user, with account details only for the user themselves
 func GetUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	db := database.DB
@@ -51,7 +76,14 @@ func GetUser(c *fiber.Ctx) error {
 	if user.Username == "" {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
 	}
-	return c.JSON(fiber.Map{"status": "success", "message": "User found", "data": user})
+	userID, _ := c.Locals("userID").(uint)
+	if userID == user.ID {
+		return c.JSON(fiber.Map{"status": "success", "message": "User found", "data": account(&user)})
+	}
+	if isBlocked(db, user.ID, userID) {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No user found with ID", "data": nil})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "User found", "data": profileSummary(&user)})
 }
 
 // CreateUser new user
@@ -95,10 +127,12 @@ func CreateUser(c *fiber.Ctx) error {
 // UpdateUser update user
 func UpdateUser(c *fiber.Ctx) error {
 	type UpdateUserInput struct {
-		Username string `json:"username" validate:"omitempty,min=3,max=50"`
-		Email    string `json:"email" validate:"omitempty,email"`
-		Password string `json:"password" validate:"omitempty,min=6,max=50"`
-		Name     string `json:"name" validate:"omitempty,min=3,max=50"`
+		Username   string  `json:"username" validate:"omitempty,min=3,max=50"`
+		Email      string  `json:"email" validate:"omitempty,email"`
+		Password   string  `json:"password" validate:"omitempty,min=6,max=50"`
+		Name       string  `json:"name" validate:"omitempty,min=3,max=50"`
+		Occupation *string `json:"occupation" validate:"omitempty,max=100"`
+		About      *string `json:"about" validate:"omitempty,max=1000"`
 	}
 
 	var uui UpdateUserInput
@@ -165,6 +199,12 @@ func UpdateUser(c *fiber.Ctx) error {
 	if uui.Name != "" {
 		user.Name = uui.Name
 	}
+	if uui.Occupation != nil {
+		user.Occupation = *uui.Occupation
+	}
+	if uui.About != nil {
+		user.About = *uui.About
+	}
 
 	// Save changes
 	if err := db.Save(&user).Error; err != nil {
@@ -178,7 +218,7 @@ func UpdateUser(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "User successfully updated",
-		"data":    user,
+		"data":    account(&user),
 	})
 }
 
@@ -205,8 +245,122 @@ func DeleteUser(c *fiber.Ctx) error {
 	db := database.DB
 	var user model.User
 
-	db.First(&user, id)
+	if err := db.First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "User not found", "errors": err.Error()})
+	}
 
-	db.Delete(&user)
+	// Delete the user together with all of their lists and goals
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return database.DeleteUser(tx, &user, time.Now())
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Couldn't delete user", "errors": err.Error()})
+	}
 	return c.JSON(fiber.Map{"status": "success", "message": "User successfully deleted", "data": nil})
 }
+
+// UserSettings is the part of a user that the settings endpoints manage
+type UserSettings struct {
+	Timezone        string `json:"timezone"`
+	QuietHoursStart *int   `json:"quiet_hours_start"`
+	QuietHoursEnd   *int   `json:"quiet_hours_end"`
+	AllowNudges     bool   `json:"allow_nudges"`
+
+	EmailReminders    bool `json:"email_reminders"`
+	ReminderLeadHours int  `json:"reminder_lead_hours"`
+	EmailHabits       bool `json:"email_habits"`
+	EmailDigest       bool `json:"email_digest"`
+}
+
+func userSettings(user *model.User) UserSettings {
+	return UserSettings{
+		Timezone:        user.Timezone,
+		QuietHoursStart: user.QuietHoursStart,
+		QuietHoursEnd:   user.QuietHoursEnd,
+		AllowNudges:     user.AllowNudges,
+
+		EmailReminders:    user.EmailReminders,
+		ReminderLeadHours: user.ReminderLeadHours,
+		EmailHabits:       user.EmailHabits,
+		EmailDigest:       user.EmailDigest,
+	}
+}
+
+// GetSettings get the current user's settings
+func GetSettings(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve user ID"})
+	}
+
+	var user model.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "User not found", "errors": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Settings found", "data": userSettings(&user)})
+}
+
+// UpdateSettings replace the current user's settings. Quiet hours are given
+// as a pair of local hours; leaving both null turns them off. Email
+// preferences that are left out are kept.
+func UpdateSettings(c *fiber.Ctx) error {
+	type UpdateSettingsInput struct {
+		Timezone        string `json:"timezone" validate:"required"`
+		QuietHoursStart *int   `json:"quiet_hours_start" validate:"omitempty,min=0,max=23"`
+		QuietHoursEnd   *int   `json:"quiet_hours_end" validate:"omitempty,min=0,max=23"`
+		AllowNudges     *bool  `json:"allow_nudges" validate:"required"`
+
+		EmailReminders    *bool `json:"email_reminders"`
+		ReminderLeadHours *int  `json:"reminder_lead_hours" validate:"omitempty,min=1,max=720"`
+		EmailHabits       *bool `json:"email_habits"`
+		EmailDigest       *bool `json:"email_digest"`
+	}
+
+	var input UpdateSettingsInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Review your input", "errors": err.Error()})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Validation failed", "errors": err.Error()})
+	}
+	if (input.QuietHoursStart == nil) != (input.QuietHoursEnd == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Quiet hours need both a start and an end", "data": nil})
+	}
+	if _, err := time.LoadLocation(input.Timezone); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Unknown timezone", "data": nil})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve user ID"})
+	}
+
+	db := database.DB
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "User not found", "errors": err.Error()})
+	}
+
+	user.Timezone = input.Timezone
+	user.QuietHoursStart = input.QuietHoursStart
+	user.QuietHoursEnd = input.QuietHoursEnd
+	user.AllowNudges = *input.AllowNudges
+	if input.EmailReminders != nil {
+		user.EmailReminders = *input.EmailReminders
+	}
+	if input.ReminderLeadHours != nil {
+		user.ReminderLeadHours = *input.ReminderLeadHours
+	}
+	if input.EmailHabits != nil {
+		user.EmailHabits = *input.EmailHabits
+	}
+	if input.EmailDigest != nil {
+		user.EmailDigest = *input.EmailDigest
+	}
+
+	if err := db.Save(&user).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Couldn't update settings", "errors": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Settings updated", "data": userSettings(&user)})
+}
@@ -0,0 +1,383 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"app/database"
+	"app/model"
+	"app/outbox"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// webhookEvent returns the webhook event for an outbox event, or "" if
+// webhooks can't subscribe to it
+func webhookEvent(eventType string) string {
+	switch eventType {
+	case model.ResourceTask + "." + model.ActivityCompleted:
+		return model.WebhookTaskCompleted
+	case model.ResourceGoal + "." + model.ActivityCompleted:
+		return model.WebhookGoalCompleted
+	case model.ResourceHabit + "." + model.ActivityCompleted:
+		return model.WebhookHabitCheckedIn
+	}
+	return ""
+}
+
+// queueWebhooks queues an outbox event for the actor's webhooks, once even
+// if the relay hands the event over again
+func queueWebhooks(ctx context.Context, event *model.OutboxEvent) error {
+	webhook := webhookEvent(event.Type)
+	if webhook == "" {
+		return nil
+	}
+	var activity ActivityEvent
+	if err := outbox.Decode(event, &activity); err != nil {
+		return err
+	}
+	return outbox.Once(database.DB, subscriberWebhooks, event.ID, func(tx *gorm.DB) error {
+		return database.QueueWebhooks(tx, event.ActorID, webhook, fiber.Map{
+			"user_id":       event.ActorID,
+			"resource_type": event.ResourceType,
+			"resource_id":   event.ResourceID,
+			"goal_id":       activity.GoalID,
+			"summary":       activity.Summary,
+		})
+	})
+}
+
+// newWebhookSecret returns a random secret for signing payloads
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// validWebhookURL reports whether a URL can receive webhooks. Hosts that
+// resolve to loopback, link-local or private addresses are refused.
+func validWebhookURL(ctx context.Context, raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	return database.CheckWebhookHost(ctx, u.Hostname()) == nil
+}
+
+// loadWebhook loads the webhook in the URL if the user owns it. On failure
+// the error response has been written and the returned webhook is nil.
+func loadWebhook(c *fiber.Ctx) (*model.Webhook, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return nil, c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	var webhook model.Webhook
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("webhook_id"), userID).First(&webhook).Error; err != nil {
+		return nil, c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook not found",
+		})
+	}
+	return &webhook, nil
+}
+
+// CreateWebhook registers an endpoint for some of the user's events. The
+// secret that signs its payloads is only returned here.
+func CreateWebhook(c *fiber.Ctx) error {
+	type WebhookInput struct {
+		URL         string   `json:"url" validate:"required,url,max=2048"`
+		Description string   `json:"description" validate:"max=200"`
+		Events      []string `json:"events" validate:"required,min=1,dive,oneof=task.completed goal.completed habit.checked_in"`
+	}
+
+	var input WebhookInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+	if !validWebhookURL(c.Context(), input.URL) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook URL must be a public http or https URL",
+			"data":    nil,
+		})
+	}
+
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't generate webhook secret",
+			"errors":  err.Error(),
+		})
+	}
+
+	webhook := model.Webhook{
+		UserID:      userID,
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      input.Events,
+		Secret:      secret,
+		Active:      true,
+	}
+	if err := database.DB.Create(&webhook).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't create webhook",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhook created",
+		"data": fiber.Map{
+			"webhook": webhook,
+			"secret":  secret,
+		},
+	})
+}
+
+// GetWebhooks lists the user's webhooks
+func GetWebhooks(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve user ID",
+		})
+	}
+
+	webhooks := []model.Webhook{}
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve webhooks",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhooks retrieved successfully",
+		"data":    webhooks,
+	})
+}
+
+// UpdateWebhook changes a webhook's URL, description, events or whether it
+// is active. Omitted fields are left unchanged. Reactivating a webhook
+// resets its failure count and sends the deliveries it held back.
+func UpdateWebhook(c *fiber.Ctx) error {
+	type WebhookInput struct {
+		URL         *string  `json:"url" validate:"omitempty,url,max=2048"`
+		Description *string  `json:"description" validate:"omitempty,max=200"`
+		Events      []string `json:"events" validate:"omitempty,min=1,dive,oneof=task.completed goal.completed habit.checked_in"`
+		Active      *bool    `json:"active"`
+	}
+
+	var input WebhookInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"errors":  err.Error(),
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  err.Error(),
+		})
+	}
+	if input.URL != nil && !validWebhookURL(c.Context(), *input.URL) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook URL must be a public http or https URL",
+			"data":    nil,
+		})
+	}
+
+	webhook, err := loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+	}
+	if input.Events != nil {
+		webhook.Events = input.Events
+	}
+	resume := input.Active != nil && *input.Active && !webhook.Active
+	if input.Active != nil {
+		if resume {
+			webhook.FailureCount = 0
+			webhook.DisabledAt = nil
+		}
+		webhook.Active = *input.Active
+	}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(webhook).Error; err != nil {
+			return err
+		}
+		// Send what was held back while the webhook was inactive
+		if resume {
+			return database.ResumeWebhook(tx, webhook, time.Now())
+		}
+		return nil
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't update webhook",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhook updated",
+		"data":    webhook,
+	})
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func DeleteWebhook(c *fiber.Ctx) error {
+	webhook, err := loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("webhook_id = ?", webhook.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(webhook).Error
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't delete webhook",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhook deleted",
+		"data":    nil,
+	})
+}
+
+// GetWebhookDeliveries lists a webhook's deliveries, newest first, one page
+// at a time
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	webhook, err := loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	page, limit := pageParams(c)
+	deliveries := []model.WebhookDelivery{}
+	// Fetch one extra row to know whether there is a next page
+	if err := database.DB.Where("webhook_id = ?", webhook.ID).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).Limit(limit + 1).
+		Find(&deliveries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't retrieve deliveries",
+			"errors":  err.Error(),
+		})
+	}
+
+	hasMore := len(deliveries) > limit
+	if hasMore {
+		deliveries = deliveries[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Deliveries retrieved successfully",
+		"data": fiber.Map{
+			"items":    deliveries,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+		},
+	})
+}
+
+// RedeliverWebhook queues a past delivery's payload again as a new delivery
+func RedeliverWebhook(c *fiber.Ctx) error {
+	webhook, err := loadWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	var previous model.WebhookDelivery
+	if err := database.DB.Where("id = ? AND webhook_id = ?", c.Params("delivery_id"), webhook.ID).First(&previous).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Delivery not found",
+		})
+	}
+
+	delivery := model.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         previous.Event,
+		Payload:       previous.Payload,
+		Status:        model.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return database.QueueDelivery(tx, &delivery)
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Couldn't queue delivery",
+			"errors":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Delivery queued",
+		"data":    delivery,
+	})
+}
@@ -0,0 +1,69 @@
+// Package ical reads and writes iCalendar (RFC 5545) data
+package ical
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest a content line may be before it is folded
+const maxLineOctets = 75
+
+// Writer builds an iCalendar object one content line at a time
+type Writer struct {
+	b strings.Builder
+}
+
+// Begin opens a component, e.g. "VCALENDAR" or "VEVENT"
+func (w *Writer) Begin(component string) {
+	w.Prop("BEGIN", component)
+}
+
+// End closes a component
+func (w *Writer) End(component string) {
+	w.Prop("END", component)
+}
+
+// Prop writes a property. name may carry parameters, e.g.
+// "DTSTART;VALUE=DATE". The value is written as is; use Text for free text.
+func (w *Writer) Prop(name, value string) {
+	line := name + ":" + value
+	// Fold long lines with CRLF and a space, without splitting characters.
+	// The space counts toward the length of continuation lines.
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.b.WriteString(line[:cut])
+		w.b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.b.WriteString(line)
+	w.b.WriteString("\r\n")
+}
+
+// String returns everything written so far
+func (w *Writer) String() string {
+	return w.b.String()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// Text escapes free text for a TEXT value
+func Text(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// DateTime formats a DATE-TIME value in UTC
+func DateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// Date formats a DATE value
+func Date(t time.Time) string {
+	return t.Format("20060102")
+}
@@ -0,0 +1,115 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriterProp(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string // Lines written, without CRLF
+	}{
+		{"SUMMARY", "Short", []string{"SUMMARY:Short"}},
+		{"DTSTART;VALUE=DATE", "20240105", []string{"DTSTART;VALUE=DATE:20240105"}},
+		// Exactly 75 octets fit on one line
+		{"SUMMARY", strings.Repeat("a", 67), []string{"SUMMARY:" + strings.Repeat("a", 67)}},
+		{"SUMMARY", strings.Repeat("a", 68), []string{
+			"SUMMARY:" + strings.Repeat("a", 67),
+			" a",
+		}},
+		// Continuation lines hold 74 octets after the leading space
+		{"SUMMARY", strings.Repeat("a", 67+74+1), []string{
+			"SUMMARY:" + strings.Repeat("a", 67),
+			" " + strings.Repeat("a", 74),
+			" a",
+		}},
+		// A two-octet character straddling the limit moves to the next line
+		{"SUMMARY", strings.Repeat("a", 66) + "é", []string{
+			"SUMMARY:" + strings.Repeat("a", 66),
+			" é",
+		}},
+		// So does a three-octet one starting one or two octets before it
+		{"SUMMARY", strings.Repeat("é", 33) + "€", []string{
+			"SUMMARY:" + strings.Repeat("é", 33),
+			" €",
+		}},
+		{"SUMMARY", strings.Repeat("a", 65) + "€", []string{
+			"SUMMARY:" + strings.Repeat("a", 65),
+			" €",
+		}},
+		// A four-octet character ending exactly at the limit stays
+		{"SUMMARY", strings.Repeat("a", 63) + "😀" + "b", []string{
+			"SUMMARY:" + strings.Repeat("a", 63) + "😀",
+			" b",
+		}},
+	}
+
+	for _, tt := range tests {
+		var w Writer
+		w.Prop(tt.name, tt.value)
+		out := w.String()
+		if !strings.HasSuffix(out, "\r\n") {
+			t.Errorf("%s: output %q doesn't end in CRLF", tt.name, out)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+		if strings.Join(lines, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s:%s\ngot  %q\nwant %q", tt.name, tt.value, lines, tt.want)
+		}
+		for _, line := range lines {
+			if len(line) > maxLineOctets {
+				t.Errorf("line %q is %d octets long", line, len(line))
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("line %q splits a character", line)
+			}
+		}
+	}
+}
+
+func TestWriterPropUnfolds(t *testing.T) {
+	values := []string{
+		strings.Repeat("Ünïcödé ", 40),
+		strings.Repeat("日本語", 50),
+		strings.Repeat("😀a", 60),
+	}
+
+	for _, value := range values {
+		var w Writer
+		w.Prop("DESCRIPTION", value)
+		var got string
+		if err := unfold(strings.NewReader(w.String()), func(_ int, line string) error {
+			got = line
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if want := "DESCRIPTION:" + value; got != want {
+			t.Errorf("unfolded to %q, want %q", got, want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"line\nbreak", `line\nbreak`},
+		{"line\r\nbreak", `line\nbreak`},
+	}
+
+	for _, tt := range tests {
+		if got := Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := Unescape(Text(tt.in)); got != strings.ReplaceAll(tt.in, "\r\n", "\n") {
+			t.Errorf("Unescape(Text(%q)) = %q", tt.in, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Property is a content line, e.g. DUE;TZID=Europe/Berlin:20240105T090000
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a BEGIN/END block with its properties and nested components
+type Component struct {
+	Name       string
+	Line       int // Line the component begins on
+	Props      []Property
+	Components []*Component
+}
+
+// Prop returns the first property with the given name
+func (c *Component) Prop(name string) (Property, bool) {
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Walk calls fn for every component with the given name, at any depth
+func (c *Component) Walk(name string, fn func(*Component)) {
+	if c.Name == name {
+		fn(c)
+	}
+	for _, child := range c.Components {
+		child.Walk(name, fn)
+	}
+}
+
+// Parse reads an iCalendar stream and returns its top-level components,
+// usually a single VCALENDAR
+func Parse(r io.Reader) ([]*Component, error) {
+	var (
+		roots []*Component
+		stack []*Component
+	)
+	err := unfold(r, func(n int, line string) error {
+		prop, err := parseLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		switch prop.Name {
+		case "BEGIN":
+			comp := &Component{Name: strings.ToUpper(prop.Value), Line: n}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, comp)
+			} else {
+				roots = append(roots, comp)
+			}
+			stack = append(stack, comp)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return fmt.Errorf("line %d: unexpected END:%s", n, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return fmt.Errorf("line %d: property outside of a component", n)
+			}
+			comp := stack[len(stack)-1]
+			comp.Props = append(comp.Props, prop)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s beginning on line %d is never closed", stack[0].Name, stack[0].Line)
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("no calendar data found")
+	}
+	return roots, nil
+}
+
+// unfold joins folded lines and calls fn with each content line and the
+// number of the line it starts on
+func unfold(r io.Reader, fn func(int, string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var (
+		current string
+		start   int
+	)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			current += line[1:]
+			continue
+		}
+		if current != "" {
+			if err := fn(start, current); err != nil {
+				return err
+			}
+		}
+		current, start = line, n
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if current != "" {
+		return fn(start, current)
+	}
+	return nil
+}
+
+// parseLine splits a content line into its name, parameters and value.
+// Parameter values may be quoted, and quoted values may contain ":" and ";".
+func parseLine(line string) (Property, error) {
+	prop := Property{Params: map[string]string{}}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, fmt.Errorf("malformed content line %q", line)
+	}
+	prop.Name = strings.ToUpper(line[:i])
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return prop, fmt.Errorf("malformed parameter in %s", prop.Name)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return prop, fmt.Errorf("unterminated quote in %s", prop.Name)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return prop, fmt.Errorf("missing value in %s", prop.Name)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		prop.Params[key] = value
+		if rest == "" || (rest[0] != ';' && rest[0] != ':') {
+			return prop, fmt.Errorf("malformed parameter in %s", prop.Name)
+		}
+		line, i = rest, 0
+	}
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// Unescape reverses Text
+func Unescape(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// List splits a multi-valued TEXT property, e.g. CATEGORIES, on unescaped
+// commas and unescapes each value
+func List(s string) []string {
+	var (
+		values []string
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, Unescape(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, Unescape(s[start:]))
+}
+
+// Time parses a DATE or DATE-TIME property. Times in UTC ("Z") are absolute;
+// times with a TZID are in that zone, and floating times and dates are in
+// loc. dateOnly reports whether the value was a DATE.
+func (p Property) Time(loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	if tzid, ok := p.Params["TZID"]; ok {
+		if zone, err := time.LoadLocation(strings.Trim(tzid, "/")); err == nil {
+			loc = zone
+		}
+	}
+	value := strings.TrimSpace(p.Value)
+	switch {
+	case p.Params["VALUE"] == "DATE" || len(value) == 8:
+		t, err = time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+}
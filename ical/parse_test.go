@@ -0,0 +1,170 @@
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Property
+	}{
+		{"SUMMARY:Buy milk", Property{Name: "SUMMARY", Params: map[string]string{}, Value: "Buy milk"}},
+		{"summary:lower case name", Property{Name: "SUMMARY", Params: map[string]string{}, Value: "lower case name"}},
+		{"DESCRIPTION:", Property{Name: "DESCRIPTION", Params: map[string]string{}, Value: ""}},
+		// Only the first colon ends the name
+		{"URL:https://example.com/a:b", Property{Name: "URL", Params: map[string]string{}, Value: "https://example.com/a:b"}},
+		{"DUE;TZID=Europe/Berlin:20240105T090000", Property{
+			Name:   "DUE",
+			Params: map[string]string{"TZID": "Europe/Berlin"},
+			Value:  "20240105T090000",
+		}},
+		{"DTSTART;value=DATE;X-FOO=bar:20240105", Property{
+			Name:   "DTSTART",
+			Params: map[string]string{"VALUE": "DATE", "X-FOO": "bar"},
+			Value:  "20240105",
+		}},
+		// Quoted parameter values may contain ":" and ";"
+		{`ATTENDEE;CN="Doe; John";DIR="ldap://example.com:6666/o=ABC":mailto:jdoe@example.com`, Property{
+			Name:   "ATTENDEE",
+			Params: map[string]string{"CN": "Doe; John", "DIR": "ldap://example.com:6666/o=ABC"},
+			Value:  "mailto:jdoe@example.com",
+		}},
+		{`X-A;P="":v`, Property{Name: "X-A", Params: map[string]string{"P": ""}, Value: "v"}},
+		// Escaped commas are left for List and Unescape
+		{`CATEGORIES:Work\,Home,Errands`, Property{Name: "CATEGORIES", Params: map[string]string{}, Value: `Work\,Home,Errands`}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLine(tt.line)
+		if err != nil {
+			t.Errorf("parseLine(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"no separator",
+		":value without name",
+		";P=x:value without name",
+		"X;:v",
+		"X;=a:v",
+		"X;P=a",
+		`X;P="unterminated:v`,
+		`X;P="quoted"junk:v`,
+	}
+
+	for _, line := range tests {
+		if _, err := parseLine(line); err == nil {
+			t.Errorf("parseLine(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestUnfold(t *testing.T) {
+	type line struct {
+		n    int
+		text string
+	}
+	tests := []struct {
+		in   string
+		want []line
+	}{
+		{"A:1\r\nB:2\r\n", []line{{1, "A:1"}, {2, "B:2"}}},
+		{"A:1\nB:2", []line{{1, "A:1"}, {2, "B:2"}}},
+		{"\ufeffA:1\r\n", []line{{1, "A:1"}}},
+		// Folds start with a space or a tab, which is dropped
+		{"A:hel\r\n lo\r\n\twor\r\n ld\r\nB:2\r\n", []line{{1, "A:helloworld"}, {5, "B:2"}}},
+		// Spaces after the first one are part of the value
+		{"A:one\r\n  two\r\n", []line{{1, "A:one two"}}},
+		// A character split across a fold is joined again
+		{"A:caf\xc3\r\n \xa9\r\n", []line{{1, "A:café"}}},
+		{"\r\nA:1\r\n\r\n", []line{{2, "A:1"}}},
+	}
+
+	for _, tt := range tests {
+		var got []line
+		err := unfold(strings.NewReader(tt.in), func(n int, text string) error {
+			got = append(got, line{n, text})
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unfold(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unfold(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{""}},
+		{"Work", []string{"Work"}},
+		{"Work,Home", []string{"Work", "Home"}},
+		{`Work\,Home,Errands`, []string{"Work,Home", "Errands"}},
+		{`a\\,b`, []string{`a\`, "b"}},
+		{`a\\\,b`, []string{`a\,b`}},
+		{`semi\;colon,new\nline`, []string{"semi;colon", "new\nline"}},
+		{"a,,b,", []string{"a", "", "b", ""}},
+	}
+
+	for _, tt := range tests {
+		if got := List(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	in := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:Buy\r\n  milk\r\n" +
+		"CATEGORIES:Home\\,Garden,Errands\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	roots, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || roots[0].Name != "VCALENDAR" || len(roots[0].Components) != 1 {
+		t.Fatalf("unexpected components %+v", roots)
+	}
+	todo := roots[0].Components[0]
+	if todo.Name != "VTODO" || todo.Line != 3 {
+		t.Errorf("got %s on line %d, want VTODO on line 3", todo.Name, todo.Line)
+	}
+	if summary, _ := todo.Prop("SUMMARY"); summary.Value != "Buy milk" {
+		t.Errorf("SUMMARY = %q, want %q", summary.Value, "Buy milk")
+	}
+	categories, _ := todo.Prop("CATEGORIES")
+	if got, want := List(categories.Value), []string{"Home,Garden", "Errands"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CATEGORIES = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"SUMMARY:outside\r\n",
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nEND:VTODO\r\n",
+		"BEGIN:VCALENDAR\r\nmalformed\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", bad)
+		}
+	}
+}
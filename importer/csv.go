@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Mapping says which CSV columns hold which task fields. Columns are named
+// by their header, case-insensitively. Unset fields fall back to common
+// header names, e.g. "title" or "task" for the text.
+type Mapping struct {
+	Text           string `json:"text"`
+	DueDate        string `json:"due_date"`
+	Completed      string `json:"completed"`
+	Labels         string `json:"labels"`
+	DateFormat     string `json:"date_format"`     // Go time layout, e.g. "02.01.2006"; common formats are tried if empty
+	Delimiter      string `json:"delimiter"`       // Defaults to ","
+	LabelSeparator string `json:"label_separator"` // Separates labels within the labels column, defaults to ","
+}
+
+// Header names tried for fields the mapping leaves empty
+var (
+	textHeaders      = []string{"text", "title", "task", "name", "content", "summary"}
+	dueDateHeaders   = []string{"due_date", "due date", "due", "deadline"}
+	completedHeaders = []string{"completed", "done", "status"}
+	labelsHeaders    = []string{"labels", "label", "tags", "categories"}
+)
+
+// dateFormats are tried in order when the mapping has no date format
+var dateFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseCSV reads tasks from a CSV file with a header row. Dates without a
+// zone are read in loc. Each task's Row is its line in the file.
+func ParseCSV(r io.Reader, mapping Mapping, loc *time.Location) (*Result, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if mapping.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(mapping.Delimiter)
+		if size != len(mapping.Delimiter) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+			return nil, errors.New("the delimiter must be a single character")
+		}
+		reader.Comma = delimiter
+	}
+	if mapping.LabelSeparator == "" {
+		mapping.LabelSeparator = ","
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	text, err := column(header, "text", mapping.Text, textHeaders)
+	if err != nil {
+		return nil, err
+	}
+	if text < 0 {
+		return nil, errors.New("no column for the task text; set it in the mapping")
+	}
+	due, err := column(header, "due_date", mapping.DueDate, dueDateHeaders)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := column(header, "completed", mapping.Completed, completedHeaders)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := column(header, "labels", mapping.Labels, labelsHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Tasks: []Task{}, Errors: []RowError{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.fail(parseErr.StartLine, parseErr.Err.Error())
+				continue
+			}
+			return nil, err
+		}
+		row, _ := reader.FieldPos(0)
+
+		if isBlank(record) {
+			continue
+		}
+		task := Task{Row: row, Text: field(record, text)}
+		if value := field(record, due); value != "" {
+			t, err := parseDate(value, mapping.DateFormat, loc)
+			if err != nil {
+				result.fail(row, fmt.Sprintf("invalid due date %q", value))
+				continue
+			}
+			task.DueDate = &t
+		}
+		if value := field(record, completed); value != "" {
+			done, ok := parseCompleted(value)
+			if !ok {
+				result.fail(row, fmt.Sprintf("invalid completed value %q", value))
+				continue
+			}
+			task.Completed = done
+		}
+		if value := field(record, labels); value != "" {
+			task.Labels = strings.Split(value, mapping.LabelSeparator)
+		}
+		result.add(task)
+	}
+	return result, nil
+}
+
+// column finds a field's column. A column named in the mapping must exist;
+// otherwise the first matching fallback is used, or -1 if there is none.
+func column(header []string, field, name string, fallbacks []string) (int, error) {
+	find := func(name string) int {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if name != "" {
+		i := find(name)
+		if i < 0 {
+			return -1, fmt.Errorf("column %q for %s not found", name, field)
+		}
+		return i, nil
+	}
+	for _, name := range fallbacks {
+		if i := find(name); i >= 0 {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseDate(value, layout string, loc *time.Location) (time.Time, error) {
+	if layout != "" {
+		return time.ParseInLocation(layout, value, loc)
+	}
+	for _, layout := range dateFormats {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unknown date format")
+}
+
+func parseCompleted(value string) (completed, ok bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "x", "done", "complete", "completed":
+		return true, true
+	case "false", "no", "n", "0", "todo", "open", "pending", "needs-action":
+		return false, true
+	}
+	return false, false
+}
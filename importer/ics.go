@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"app/ical"
+)
+
+// ParseICS reads the VTODOs of an iCalendar file. Times without a zone are
+// read in loc, and due dates without a time fall at the start of the day.
+// Each task's Row is the line its VTODO begins on.
+func ParseICS(r io.Reader, loc *time.Location) (*Result, error) {
+	calendars, err := ical.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Tasks: []Task{}, Errors: []RowError{}}
+	for _, calendar := range calendars {
+		if name, ok := calendar.Prop("X-WR-CALNAME"); ok && result.Name == "" {
+			result.Name = strings.TrimSpace(ical.Unescape(name.Value))
+		}
+		calendar.Walk("VTODO", func(todo *ical.Component) {
+			task, err := icsTask(todo, loc)
+			if err != nil {
+				result.fail(todo.Line, err.Error())
+				return
+			}
+			result.add(task)
+		})
+	}
+	return result, nil
+}
+
+func icsTask(todo *ical.Component, loc *time.Location) (Task, error) {
+	task := Task{Row: todo.Line}
+	if summary, ok := todo.Prop("SUMMARY"); ok {
+		task.Text = ical.Unescape(summary.Value)
+	}
+
+	// Fall back to the start for to-dos with a start but no due date
+	due, ok := todo.Prop("DUE")
+	if !ok {
+		due, ok = todo.Prop("DTSTART")
+	}
+	if ok {
+		t, _, err := due.Time(loc)
+		if err != nil {
+			return task, fmt.Errorf("invalid %s %q", due.Name, due.Value)
+		}
+		task.DueDate = &t
+	}
+
+	if status, ok := todo.Prop("STATUS"); ok && strings.EqualFold(status.Value, "COMPLETED") {
+		task.Completed = true
+	}
+	if completed, ok := todo.Prop("COMPLETED"); ok {
+		t, _, err := completed.Time(loc)
+		if err != nil {
+			return task, fmt.Errorf("invalid COMPLETED %q", completed.Value)
+		}
+		task.Completed = true
+		task.CompletedAt = &t
+	}
+
+	for _, prop := range todo.Props {
+		if prop.Name == "CATEGORIES" {
+			task.Labels = append(task.Labels, ical.List(prop.Value)...)
+		}
+	}
+	return task, nil
+}
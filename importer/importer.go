@@ -0,0 +1,213 @@
+// Package importer reads tasks exported from other tools
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTasks is the most tasks, including subtasks, a single import may
+	// contain
+	MaxTasks = 5000
+	// maxLabelLength matches the size of model.Label.Name
+	maxLabelLength = 50
+)
+
+// Task is an imported task, not yet saved
+type Task struct {
+	Row         int        `json:"row"` // Where the task came from, e.g. the CSV line
+	Text        string     `json:"text"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Labels      []string   `json:"labels,omitempty"`
+	Subtasks    []Task     `json:"subtasks,omitempty"` // E.g. checklist items
+}
+
+// count returns the number of tasks in the tree rooted at t
+func (t *Task) count() int {
+	n := 1
+	for i := range t.Subtasks {
+		n += t.Subtasks[i].count()
+	}
+	return n
+}
+
+// maxDepth is how deep subtasks may nest; deeper ones are dropped and
+// reported
+const maxDepth = 10
+
+// node is a task whose subtasks are still being collected
+type node struct {
+	task     Task
+	children []*node
+}
+
+// build returns the node's task with its subtasks, reporting those nested
+// too deep to the export
+func (n *node) build(export *Export, depth int) Task {
+	task := n.task
+	for _, child := range n.children {
+		if depth >= maxDepth {
+			export.fail(child.task.Row, fmt.Sprintf("subtasks can only be nested %d levels deep", maxDepth))
+			continue
+		}
+		task.Subtasks = append(task.Subtasks, child.build(export, depth+1))
+	}
+	return task
+}
+
+// RowError reports a task that couldn't be imported, or only in part
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// Result is everything read from an import file. Rows with errors are left
+// out of Tasks.
+type Result struct {
+	Name   string     `json:"name,omitempty"` // List name suggested by the file, if any
+	Tasks  []Task     `json:"tasks"`
+	Errors []RowError `json:"errors"`
+}
+
+// add checks a task and adds it, or records why it was skipped
+func (r *Result) add(task Task) {
+	if err := clean(&task); err != nil {
+		r.fail(task.Row, err.Error())
+		return
+	}
+	if len(r.Tasks) >= MaxTasks {
+		r.fail(task.Row, fmt.Sprintf("an import can contain at most %d tasks", MaxTasks))
+		return
+	}
+	r.Tasks = append(r.Tasks, task)
+}
+
+func (r *Result) fail(row int, msg string) {
+	r.Errors = append(r.Errors, RowError{Row: row, Error: msg})
+}
+
+// List is a list of tasks in an export, e.g. a Todoist project or a Trello
+// board
+type List struct {
+	Name  string `json:"name"`
+	Tasks []Task `json:"tasks"`
+}
+
+// Export is everything read from another tool's export. Tasks with errors,
+// along with their subtasks, are left out of the lists.
+type Export struct {
+	Lists  []*List    `json:"lists"`
+	Errors []RowError `json:"errors"`
+	count  int
+}
+
+func newExport() *Export {
+	return &Export{Lists: []*List{}, Errors: []RowError{}}
+}
+
+// Count returns the number of tasks in the export, including subtasks
+func (e *Export) Count() int {
+	return e.count
+}
+
+// list adds an empty list
+func (e *Export) list(name string) *List {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Imported tasks"
+	}
+	list := &List{Name: name, Tasks: []Task{}}
+	e.Lists = append(e.Lists, list)
+	return list
+}
+
+// add checks a task and its subtasks and adds them to a list, or records
+// why they were skipped
+func (e *Export) add(list *List, task Task) {
+	if !e.check(&task) {
+		return
+	}
+	list.Tasks = append(list.Tasks, task)
+}
+
+func (e *Export) check(task *Task) bool {
+	if err := clean(task); err != nil {
+		e.fail(task.Row, err.Error())
+		return false
+	}
+	if e.count+task.count() > MaxTasks {
+		e.fail(task.Row, fmt.Sprintf("an import can contain at most %d tasks", MaxTasks))
+		return false
+	}
+	e.count++
+	subtasks := task.Subtasks[:0]
+	for _, subtask := range task.Subtasks {
+		if e.check(&subtask) {
+			subtasks = append(subtasks, subtask)
+		}
+	}
+	task.Subtasks = subtasks
+	return true
+}
+
+func (e *Export) fail(row int, msg string) {
+	e.Errors = append(e.Errors, RowError{Row: row, Error: msg})
+}
+
+// clean trims a task's text and labels and checks they can be saved. It
+// leaves the subtasks alone.
+func clean(task *Task) error {
+	task.Text = strings.TrimSpace(task.Text)
+	if task.Text == "" {
+		return errors.New("task text is empty")
+	}
+
+	labels := task.Labels[:0]
+	seen := map[string]bool{}
+	for _, label := range task.Labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		if utf8.RuneCountInString(label) > maxLabelLength {
+			return fmt.Errorf("label %q is longer than %d characters", label, maxLabelLength)
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	task.Labels = labels
+	return nil
+}
+
+// Tools whose exports can be imported
+const (
+	SourceTodoist = "todoist"
+	SourceTrello  = "trello"
+	SourceMSTodo  = "mstodo"
+)
+
+// Parse reads an export of the given tool. Todoist backups may be JSON or a
+// single project's CSV, which is named after fileName.
+func Parse(source string, data []byte, fileName string, loc *time.Location) (*Export, error) {
+	r := bytes.NewReader(data)
+	switch source {
+	case SourceTodoist:
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			return ParseTodoistJSON(r, loc)
+		}
+		return ParseTodoistCSV(r, strings.TrimSuffix(fileName, filepath.Ext(fileName)), loc)
+	case SourceTrello:
+		return ParseTrello(r, loc)
+	case SourceMSTodo:
+		return ParseMSTodo(r, loc)
+	}
+	return nil, fmt.Errorf("unknown import source %q", source)
+}
@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outline renders an export as one line per list and task, with subtasks
+// indented under their parents
+func outline(e *Export) []string {
+	var lines []string
+	var add func(tasks []Task, depth int)
+	add = func(tasks []Task, depth int) {
+		for _, t := range tasks {
+			line := fmt.Sprintf("%s%d %s", strings.Repeat("  ", depth), t.Row, t.Text)
+			if t.Completed {
+				line += " [x]"
+			}
+			if len(t.Labels) > 0 {
+				line += " #" + strings.Join(t.Labels, ",")
+			}
+			if t.DueDate != nil {
+				line += " due " + t.DueDate.UTC().Format("2006-01-02 15:04")
+			}
+			lines = append(lines, line)
+			add(t.Subtasks, depth+1)
+		}
+	}
+	for _, list := range e.Lists {
+		lines = append(lines, list.Name)
+		add(list.Tasks, 1)
+	}
+	return lines
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		fileName string
+		data     string
+		want     []string
+		errors   []RowError
+	}{
+		{
+			name:   "Todoist JSON",
+			source: SourceTodoist,
+			data: `{
+				"projects": [{"id": "1", "name": "Home"}, {"id": 2, "name": "Old", "is_archived": 1}],
+				"sections": [{"id": "s1", "name": "Garden"}],
+				"items": [
+					{"id": "11", "project_id": "1", "content": "Child", "parent_id": "10", "child_order": 1},
+					{"id": "10", "project_id": "1", "content": "Parent", "section_id": "s1", "child_order": 2, "labels": ["urgent"], "due": {"date": "2024-01-05"}},
+					{"id": "12", "project_id": 1, "content": "Done", "checked": 1, "completed_at": "2024-01-02T10:00:00Z", "child_order": 3},
+					{"id": "13", "project_id": "1", "content": "Deleted", "is_deleted": true},
+					{"id": "14", "project_id": "2", "content": "In an archived project"},
+					{"id": "15", "project_id": "1", "content": "Cycle A", "parent_id": "16"},
+					{"id": "16", "project_id": "1", "content": "Cycle B", "parent_id": "15"},
+					{"id": "17", "project_id": "1", "content": "  "},
+					{"id": "18", "project_id": "1", "content": "Bad due date", "due": {"date": "next week"}}
+				]
+			}`,
+			want: []string{
+				"Home",
+				"  2 Parent #urgent,Garden due 2024-01-05 00:00",
+				"    1 Child",
+				"  3 Done [x]",
+			},
+			errors: []RowError{
+				{9, `invalid due date "next week"`},
+				{6, "the task's parent tasks form a cycle"},
+				{7, "the task's parent tasks form a cycle"},
+				{5, "the task's project is archived, deleted or missing"},
+				{8, "task text is empty"},
+			},
+		},
+		{
+			name:     "Todoist CSV",
+			source:   SourceTodoist,
+			fileName: "Groceries.csv",
+			data: "\ufeffTYPE,CONTENT,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+				"task,Top,4,1,,,2024-01-05,en,\n" +
+				"task,Sub,4,2,,,,en,\n" +
+				"task,Too deep,4,4,,,every monday,en,\n" +
+				",,,,,,,,\n" +
+				"section,Errands,,,,,,,\n" +
+				"task,Milk,4,1,,,,,\n" +
+				"note,A comment,,,,,,,\n",
+			want: []string{
+				"Groceries",
+				"  2 Top due 2024-01-05 00:00",
+				"    3 Sub",
+				"      4 Too deep",
+				"  7 Milk #Errands",
+			},
+			errors: []RowError{
+				{4, `due date "every monday" can't be read; imported without it`},
+			},
+		},
+		{
+			name:   "Trello",
+			source: SourceTrello,
+			data: `{
+				"name": "Board",
+				"lists": [{"id": "l1", "name": "To do"}, {"id": "l2", "name": "Done"}, {"id": "l3", "name": "Old", "closed": true}],
+				"cards": [
+					{"id": "c1", "name": "Second", "idList": "l1", "pos": 200},
+					{"id": "c2", "name": "Finished", "idList": "l2", "pos": 1, "dueComplete": true, "labels": [{"name": "red"}]},
+					{"id": "c3", "name": "First", "idList": "l1", "pos": 100, "due": "2024-01-05T09:00:00.000Z"},
+					{"id": "c4", "name": "Archived", "idList": "l1", "closed": true},
+					{"id": "c5", "name": "In an archived column", "idList": "l3"},
+					{"id": "c6", "name": "Bad due date", "idList": "l1", "due": "soon"}
+				],
+				"checklists": [{"idCard": "c3", "pos": 1, "checkItems": [
+					{"name": "B", "state": "incomplete", "pos": 2},
+					{"name": "A", "state": "complete", "pos": 1}
+				]}]
+			}`,
+			want: []string{
+				"Board",
+				"  3 First #To do due 2024-01-05 09:00",
+				"    3 A [x]",
+				"    3 B",
+				"  1 Second #To do",
+				"  2 Finished [x] #Done,red",
+			},
+			errors: []RowError{
+				{6, `invalid due date "soon"`},
+			},
+		},
+		{
+			name:   "Microsoft To Do",
+			source: SourceMSTodo,
+			data: `{"value": [
+				{"displayName": "Tasks", "tasks": [
+					{"title": "Call", "status": "notStarted", "categories": ["Work"],
+						"dueDateTime": {"dateTime": "2024-01-05T00:00:00.0000000", "timeZone": "UTC"},
+						"checklistItems": [{"displayName": "Dial", "isChecked": true, "checkedDateTime": "2024-01-04T10:00:00Z"}]},
+					{"title": "Bad due date", "dueDateTime": {"dateTime": "tomorrow", "timeZone": "UTC"}}
+				]},
+				{"displayName": "", "tasks": [{"title": "Done", "status": "completed"}]}
+			]}`,
+			want: []string{
+				"Tasks",
+				"  1 Call #Work due 2024-01-05 00:00",
+				"    1 Dial [x]",
+				"Imported tasks",
+				"  3 Done [x]",
+			},
+			errors: []RowError{
+				{2, `invalid due date "tomorrow"`},
+			},
+		},
+		{
+			name:   "Microsoft To Do array",
+			source: SourceMSTodo,
+			data:   `[{"displayName": "Shopping", "tasks": [{"title": "Bread"}]}]`,
+			want:   []string{"Shopping", "  1 Bread"},
+		},
+	}
+
+	for _, tt := range tests {
+		export, err := Parse(tt.source, []byte(tt.data), tt.fileName, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := outline(export); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+		if (len(export.Errors) > 0 || len(tt.errors) > 0) && !reflect.DeepEqual(export.Errors, tt.errors) {
+			t.Errorf("%s: errors = %v, want %v", tt.name, export.Errors, tt.errors)
+		}
+	}
+}
+
+func TestParseTodoistDepth(t *testing.T) {
+	// A chain of subtasks one longer than allowed
+	var items []string
+	for i := 1; i <= maxDepth+2; i++ {
+		items = append(items, fmt.Sprintf(`{"id": "%d", "project_id": "1", "content": "Level %d", "parent_id": "%d"}`, i, i, i-1))
+	}
+	data := `{"projects": [{"id": "1", "name": "Deep"}], "items": [` + strings.Join(items, ",") + `]}`
+
+	export, err := Parse(SourceTodoist, []byte(data), "", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := export.Count(), maxDepth+1; got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+	want := []RowError{{maxDepth + 2, fmt.Sprintf("subtasks can only be nested %d levels deep", maxDepth)}}
+	if !reflect.DeepEqual(export.Errors, want) {
+		t.Errorf("errors = %v, want %v", export.Errors, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		data   string
+	}{
+		{SourceTodoist, ""},
+		{SourceTodoist, "{"},
+		{SourceTodoist, "{}"},
+		{SourceTodoist, "name,date\nMilk,2024-01-05\n"},
+		{SourceTrello, "{}"},
+		{SourceTrello, "[]"},
+		{SourceMSTodo, "[]"},
+		{SourceMSTodo, "{}"},
+		{SourceMSTodo, "not json"},
+		{"asana", "{}"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.source, []byte(tt.data), "export.csv", time.UTC); err == nil {
+			t.Errorf("Parse(%q, %q) succeeded, want an error", tt.source, tt.data)
+		}
+	}
+}
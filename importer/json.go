@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// id is an ID that some exports write as a string and others as a number
+type id string
+
+func (i *id) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*i = ""
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*i = id(s)
+		return nil
+	}
+	*i = id(b)
+	return nil
+}
+
+// flag is a boolean that some exports write as 0 or 1
+type flag bool
+
+func (f *flag) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "true", "1":
+		*f = true
+	case "false", "0", "null":
+		*f = false
+	default:
+		n, err := strconv.Atoi(string(b))
+		if err != nil {
+			return err
+		}
+		*f = n != 0
+	}
+	return nil
+}
+
+// timestamp parses an optional timestamp in one of the common formats
+func timestamp(value *string, loc *time.Location) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := parseDate(*value, "", loc)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type msTodoList struct {
+	DisplayName string       `json:"displayName"`
+	Tasks       []msTodoTask `json:"tasks"`
+}
+
+type msTodoTask struct {
+	Title             string          `json:"title"`
+	Status            string          `json:"status"`
+	DueDateTime       *msTodoDateTime `json:"dueDateTime"`
+	CompletedDateTime *msTodoDateTime `json:"completedDateTime"`
+	Categories        []string        `json:"categories"`
+	ChecklistItems    []struct {
+		DisplayName     string  `json:"displayName"`
+		IsChecked       bool    `json:"isChecked"`
+		CheckedDateTime *string `json:"checkedDateTime"`
+	} `json:"checklistItems"`
+}
+
+// msTodoDateTime is a Microsoft Graph dateTimeTimeZone
+type msTodoDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+func (d *msTodoDateTime) time(loc *time.Location) (*time.Time, error) {
+	if d == nil || d.DateTime == "" {
+		return nil, nil
+	}
+	if zone, err := time.LoadLocation(d.TimeZone); err == nil && d.TimeZone != "" {
+		loc = zone
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.9999999", d.DateTime, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ParseMSTodo reads Microsoft To Do lists as returned by Microsoft Graph:
+// an array of todoTaskLists, or an object with them under "lists" or
+// "value", each with its todoTasks under "tasks". Checklist items become
+// subtasks and categories become labels. Each task's Row counts the tasks
+// across all lists, from 1.
+func ParseMSTodo(r io.Reader, loc *time.Location) (*Export, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var lists []msTodoList
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &lists)
+	} else {
+		var wrapped struct {
+			Lists []msTodoList `json:"lists"`
+			Value []msTodoList `json:"value"`
+		}
+		err = json.Unmarshal(trimmed, &wrapped)
+		lists = append(wrapped.Lists, wrapped.Value...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a Microsoft To Do export: %w", err)
+	}
+	if len(lists) == 0 {
+		return nil, errors.New("not a Microsoft To Do export: no lists found")
+	}
+
+	export := newExport()
+	row := 0
+	for _, l := range lists {
+		list := export.list(l.DisplayName)
+		for _, t := range l.Tasks {
+			row++
+			task := Task{Row: row, Text: t.Title, Completed: t.Status == "completed", Labels: t.Categories}
+			due, err := t.DueDateTime.time(loc)
+			if err != nil {
+				export.fail(row, fmt.Sprintf("invalid due date %q", t.DueDateTime.DateTime))
+				continue
+			}
+			task.DueDate = due
+			completedAt, err := t.CompletedDateTime.time(loc)
+			if err != nil {
+				export.fail(row, fmt.Sprintf("invalid completion time %q", t.CompletedDateTime.DateTime))
+				continue
+			}
+			if completedAt != nil {
+				task.Completed = true
+				task.CompletedAt = completedAt
+			}
+			for _, item := range t.ChecklistItems {
+				checkedAt, _ := timestamp(item.CheckedDateTime, loc)
+				task.Subtasks = append(task.Subtasks, Task{
+					Row:         row,
+					Text:        item.DisplayName,
+					Completed:   item.IsChecked,
+					CompletedAt: checkedAt,
+				})
+			}
+			export.add(list, task)
+		}
+	}
+	return export, nil
+}
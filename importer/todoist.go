@@ -0,0 +1,255 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type todoistBackup struct {
+	Projects []struct {
+		ID         id     `json:"id"`
+		Name       string `json:"name"`
+		IsArchived flag   `json:"is_archived"`
+		IsDeleted  flag   `json:"is_deleted"`
+	} `json:"projects"`
+	Sections []struct {
+		ID   id     `json:"id"`
+		Name string `json:"name"`
+	} `json:"sections"`
+	Items []todoistItem `json:"items"`
+}
+
+type todoistItem struct {
+	ID          id       `json:"id"`
+	ProjectID   id       `json:"project_id"`
+	SectionID   id       `json:"section_id"`
+	ParentID    id       `json:"parent_id"`
+	Content     string   `json:"content"`
+	Checked     flag     `json:"checked"`
+	IsDeleted   flag     `json:"is_deleted"`
+	CompletedAt *string  `json:"completed_at"`
+	ChildOrder  int      `json:"child_order"`
+	Labels      []string `json:"labels"`
+	Due         *struct {
+		Date string `json:"date"`
+	} `json:"due"`
+}
+
+// ParseTodoistJSON reads a Todoist backup in the format of the Sync API,
+// with "projects", "sections" and "items". Each project becomes a list,
+// sub-tasks become subtasks and sections become labels. Each task's Row is
+// its position in "items".
+func ParseTodoistJSON(r io.Reader, loc *time.Location) (*Export, error) {
+	var backup todoistBackup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("not a Todoist backup: %w", err)
+	}
+	if len(backup.Projects) == 0 && len(backup.Items) == 0 {
+		return nil, errors.New("not a Todoist backup: no projects or items found")
+	}
+
+	sections := map[id]string{}
+	for _, s := range backup.Sections {
+		sections[s.ID] = s.Name
+	}
+
+	// Subtasks may come before their parents, so build the tree first
+	nodes := map[id]*node{}
+	items := map[*node]*todoistItem{}
+	var order []*node
+	export := newExport()
+	for i := range backup.Items {
+		item := &backup.Items[i]
+		if item.IsDeleted {
+			continue
+		}
+		task := Task{Row: i + 1, Text: item.Content, Completed: bool(item.Checked), Labels: item.Labels}
+		if name, ok := sections[item.SectionID]; ok {
+			task.Labels = append(task.Labels, name)
+		}
+		if item.Due != nil && item.Due.Date != "" {
+			due, err := parseDate(item.Due.Date, "", loc)
+			if err != nil {
+				export.fail(task.Row, fmt.Sprintf("invalid due date %q", item.Due.Date))
+				continue
+			}
+			task.DueDate = &due
+		}
+		completedAt, err := timestamp(item.CompletedAt, loc)
+		if err != nil {
+			export.fail(task.Row, fmt.Sprintf("invalid completion time %q", *item.CompletedAt))
+			continue
+		}
+		if completedAt != nil {
+			task.Completed = true
+			task.CompletedAt = completedAt
+		}
+		n := &node{task: task}
+		nodes[item.ID] = n
+		items[n] = item
+		order = append(order, n)
+	}
+
+	// Items are ordered within their parent by child_order
+	sort.SliceStable(order, func(i, j int) bool { return items[order[i]].ChildOrder < items[order[j]].ChildOrder })
+	var roots []*node
+	for _, n := range order {
+		parentID := items[n].ParentID
+		if parent, ok := nodes[parentID]; ok && parentID != "" && parent != n {
+			parent.children = append(parent.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	// Items whose parents form a cycle can't be reached from any root
+	reached := map[*node]bool{}
+	var reach func(n *node)
+	reach = func(n *node) {
+		if reached[n] {
+			return
+		}
+		reached[n] = true
+		for _, child := range n.children {
+			reach(child)
+		}
+	}
+	for _, n := range roots {
+		reach(n)
+	}
+	for _, n := range order {
+		if !reached[n] {
+			export.fail(n.task.Row, "the task's parent tasks form a cycle")
+		}
+	}
+
+	lists := map[id]*List{}
+	for _, p := range backup.Projects {
+		if p.IsDeleted || p.IsArchived {
+			continue
+		}
+		lists[p.ID] = export.list(p.Name)
+	}
+	for _, n := range roots {
+		list, ok := lists[items[n].ProjectID]
+		if !ok {
+			export.fail(n.task.Row, "the task's project is archived, deleted or missing")
+			continue
+		}
+		export.add(list, n.build(export, 0))
+	}
+	return export, nil
+}
+
+// ParseTodoistCSV reads a project exported from Todoist as CSV into a list
+// with the given name. INDENT nests tasks as subtasks and sections become
+// labels. Due dates Todoist wrote in words, e.g. "every monday", can't be
+// read; those tasks are imported without one and reported. Each task's Row
+// is its line in the file.
+func ParseTodoistCSV(r io.Reader, name string, loc *time.Location) (*Export, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToUpper(strings.TrimSpace(h))] = i
+	}
+	get := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return field(record, i)
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return nil, errors.New("not a Todoist export: no TYPE column")
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return nil, errors.New("not a Todoist export: no CONTENT column")
+	}
+
+	export := newExport()
+	list := export.list(name)
+
+	// stack holds the open task at each indent level, starting at 1
+	var (
+		stack   []*node
+		section string
+		roots   []*node
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				export.fail(parseErr.StartLine, parseErr.Err.Error())
+				continue
+			}
+			return nil, err
+		}
+		row, _ := reader.FieldPos(0)
+
+		switch strings.ToLower(get(record, "TYPE")) {
+		case "section":
+			section = get(record, "CONTENT")
+			stack = nil
+			continue
+		case "task":
+		default:
+			// Notes, metadata and blank lines
+			continue
+		}
+
+		task := Task{Row: row, Text: get(record, "CONTENT")}
+		if section != "" {
+			task.Labels = []string{section}
+		}
+		if value := get(record, "DATE"); value != "" {
+			if due, err := parseDate(value, "", loc); err == nil {
+				task.DueDate = &due
+			} else {
+				export.fail(row, fmt.Sprintf("due date %q can't be read; imported without it", value))
+			}
+		}
+
+		indent, err := strconv.Atoi(get(record, "INDENT"))
+		if err != nil || indent < 1 {
+			indent = 1
+		}
+		if indent > len(stack)+1 {
+			indent = len(stack) + 1
+		}
+		n := &node{task: task}
+		if indent == 1 {
+			roots = append(roots, n)
+		} else {
+			parent := stack[indent-2]
+			parent.children = append(parent.children, n)
+		}
+		stack = append(stack[:indent-1], n)
+	}
+
+	for _, n := range roots {
+		export.add(list, n.build(export, 0))
+	}
+	return export, nil
+}
@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		ID          string  `json:"id"`
+		Name        string  `json:"name"`
+		IDList      string  `json:"idList"`
+		Closed      bool    `json:"closed"`
+		Due         *string `json:"due"`
+		DueComplete bool    `json:"dueComplete"`
+		Pos         float64 `json:"pos"`
+		Labels      []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Checklists []struct {
+		IDCard     string  `json:"idCard"`
+		Pos        float64 `json:"pos"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Due   *string `json:"due"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// ParseTrello reads a Trello board exported as JSON into a single list.
+// Cards become tasks, labelled with their column, and checklist items
+// become subtasks. Archived cards and columns are left out. Each task's Row
+// is its card's position in "cards".
+func ParseTrello(r io.Reader, loc *time.Location) (*Export, error) {
+	var board trelloBoard
+	if err := json.NewDecoder(r).Decode(&board); err != nil {
+		return nil, fmt.Errorf("not a Trello board: %w", err)
+	}
+	if board.Lists == nil && board.Cards == nil {
+		return nil, errors.New("not a Trello board: no lists or cards found")
+	}
+
+	export := newExport()
+	list := export.list(board.Name)
+
+	columns := map[string]int{}
+	for i, l := range board.Lists {
+		if !l.Closed {
+			columns[l.ID] = i
+		}
+	}
+
+	// Checklists in board order, grouped by card
+	sort.SliceStable(board.Checklists, func(i, j int) bool { return board.Checklists[i].Pos < board.Checklists[j].Pos })
+	subtasks := map[string][]*node{}
+	for _, checklist := range board.Checklists {
+		items := checklist.CheckItems
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Pos < items[j].Pos })
+		for _, item := range items {
+			due, _ := timestamp(item.Due, loc)
+			subtasks[checklist.IDCard] = append(subtasks[checklist.IDCard], &node{task: Task{
+				Text:      item.Name,
+				Completed: item.State == "complete",
+				DueDate:   due,
+			}})
+		}
+	}
+
+	// Cards column by column, in board order
+	type card struct {
+		row, column int
+		pos         float64
+		node        *node
+	}
+	var cards []card
+	for i, c := range board.Cards {
+		column, ok := columns[c.IDList]
+		if c.Closed || !ok {
+			continue
+		}
+		task := Task{Row: i + 1, Text: c.Name, Completed: c.DueComplete}
+		due, err := timestamp(c.Due, loc)
+		if err != nil {
+			export.fail(task.Row, fmt.Sprintf("invalid due date %q", *c.Due))
+			continue
+		}
+		task.DueDate = due
+		task.Labels = append(task.Labels, board.Lists[column].Name)
+		for _, label := range c.Labels {
+			task.Labels = append(task.Labels, label.Name)
+		}
+		n := &node{task: task, children: subtasks[c.ID]}
+		for _, child := range n.children {
+			child.task.Row = task.Row
+		}
+		cards = append(cards, card{row: task.Row, column: column, pos: c.Pos, node: n})
+	}
+	sort.SliceStable(cards, func(i, j int) bool {
+		if cards[i].column != cards[j].column {
+			return cards[i].column < cards[j].column
+		}
+		return cards[i].pos < cards[j].pos
+	})
+	for _, c := range cards {
+		export.add(list, c.node.build(export, 0))
+	}
+	return export, nil
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSpecNext(t *testing.T) {
+	at := func(s string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"@every 90s", "2024-01-01 10:00:30", "2024-01-01 10:02:00"},
+		{"@hourly", "2024-01-01 10:30:00", "2024-01-01 11:00:00"},
+		{"@hourly", "2024-01-01 11:00:00", "2024-01-01 12:00:00"},
+		{"@daily", "2024-01-31 23:59:59", "2024-02-01 00:00:00"},
+		{"@weekly", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"*/15 6-22 * * 1-5", "2024-01-01 06:07:00", "2024-01-01 06:15:00"},
+		{"*/15 6-22 * * 1-5", "2024-01-05 22:50:00", "2024-01-08 06:00:00"},
+		{"5/15 * * * *", "2024-01-01 10:00:00", "2024-01-01 10:05:00"},
+		{"5/15 * * * *", "2024-01-01 10:50:00", "2024-01-01 11:05:00"},
+		{"0 9 1,15 * *", "2024-01-02 00:00:00", "2024-01-15 09:00:00"},
+		{"0 0 1 * *", "2024-01-15 12:00:00", "2024-02-01 00:00:00"},
+		{"30 9 29 2 *", "2024-03-01 00:00:00", "2028-02-29 09:30:00"},
+		{"0 0 * 6 *", "2024-01-01 00:00:00", "2024-06-01 00:00:00"},
+		// With both days restricted either one matching is enough
+		{"0 0 13 * 5", "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"0 0 13 * 5", "2024-01-12 00:00:00", "2024-01-13 00:00:00"},
+		{"0 0 13 * 5", "2024-01-13 00:00:00", "2024-01-19 00:00:00"},
+		// A restricted day of week alone must match
+		{"0 0 * * 5", "2024-01-12 00:00:00", "2024-01-19 00:00:00"},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := spec.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.from, got.Format("2006-01-02 15:04:05"), tt.want)
+		}
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"@every",
+		"@every x",
+		"@every -1s",
+		"@monthly",
+	}
+
+	for _, spec := range tests {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package jobs runs background work from a persistent queue in Postgres.
+// Job types are registered with a typed handler, enqueued from anywhere that
+// has a database handle and claimed by the runner with SELECT ... FOR UPDATE
+// SKIP LOCKED, so any number of instances can share the queue.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Config tunes how jobs of one type are run. Zero fields take the defaults.
+type Config struct {
+	// Timeout is how long a job may run before it is cancelled and another
+	// worker may pick it up (default 5 minutes)
+	Timeout time.Duration
+	// MaxAttempts is how often a job is tried before it fails (default 5)
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles with every
+	// further attempt, up to an hour (default 30 seconds)
+	Backoff time.Duration
+}
+
+// handler runs one job of a registered type
+type handler struct {
+	cfg  Config
+	run  func(ctx context.Context, payload []byte) error
+	fail func(payload []byte, err error)
+}
+
+var (
+	mu        sync.RWMutex
+	handlers  = make(map[string]*handler)
+	schedules = make(map[string]*schedule)
+)
+
+// Register sets the handler for a job type. The job's JSON payload is
+// decoded into T before the handler is called.
+func Register[T any](jobType string, cfg Config, fn func(ctx context.Context, payload T) error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 30 * time.Second
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = &handler{
+		cfg: cfg,
+		run: func(ctx context.Context, raw []byte) error {
+			var payload T
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				return fmt.Errorf("invalid payload: %v", err)
+			}
+			return fn(ctx, payload)
+		},
+	}
+}
+
+// OnFailure sets a function that is called once a job of a registered type
+// has failed for good, after its last attempt returned an error or timed
+// out. It is not called for failures that are retried.
+func OnFailure[T any](jobType string, fn func(payload T, err error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	h := handlers[jobType]
+	if h == nil {
+		panic("jobs: OnFailure for unregistered job type " + jobType)
+	}
+	h.fail = func(raw []byte, err error) {
+		var payload T
+		if jsonErr := json.Unmarshal(raw, &payload); jsonErr != nil {
+			log.Printf("Error decoding payload of failed %s job: %v", jobType, jsonErr)
+			return
+		}
+		fn(payload, err)
+	}
+}
+
+// lookup returns the handler for a job type
+func lookup(jobType string) *handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return handlers[jobType]
+}
+
+// registeredTypes returns the job types this instance can run
+func registeredTypes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for t := range handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Options for a single enqueued job
+type Options struct {
+	// RunAt delays the job until then (default now)
+	RunAt time.Time
+	// UniqueKey skips the job while another unfinished job has the same key
+	UniqueKey string
+}
+
+// Enqueue adds a job to the queue. Pass a transaction to enqueue the job
+// only if the surrounding work commits. The job type must be registered.
+// It returns nil without error when the unique key is already taken.
+func Enqueue(tx *gorm.DB, jobType string, payload interface{}, opts Options) (*model.Job, error) {
+	h := lookup(jobType)
+	if h == nil {
+		return nil, fmt.Errorf("unknown job type %q", jobType)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := model.Job{
+		Type:        jobType,
+		Payload:     string(raw),
+		Status:      model.JobQueued,
+		RunAt:       opts.RunAt,
+		MaxAttempts: h.cfg.MaxAttempts,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if opts.UniqueKey != "" {
+		job.UniqueKey = &opts.UniqueKey
+	}
+
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&job)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// schedule is a recurring job
+type schedule struct {
+	name, spec, jobType string
+	payload             interface{}
+	parsed              Spec
+}
+
+// Schedule enqueues a job of the given type whenever the spec comes due;
+// see ParseSpec. A run is skipped while the previous one hasn't finished.
+func Schedule(name, spec, jobType string, payload interface{}) error {
+	parsed, err := ParseSpec(spec)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	schedules[name] = &schedule{name: name, spec: spec, jobType: jobType, payload: payload, parsed: parsed}
+	return nil
+}
+
+// syncSchedules stores the declared schedules. A new schedule, or one whose
+// spec changed, is next due at its next run from now.
+func syncSchedules(db *gorm.DB, now time.Time) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, s := range schedules {
+		row := model.JobSchedule{Name: s.name, Spec: s.spec, NextRunAt: s.parsed.Next(now)}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"spec": s.spec, "next_run_at": row.NextRunAt}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "job_schedules.spec <> excluded.spec"}}},
+		}).Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueDue enqueues a run of every schedule that has come due
+func enqueueDue(db *gorm.DB, now time.Time) error {
+	// Enqueue looks up handlers, so don't hold the lock while enqueueing
+	mu.RLock()
+	declared := make(map[string]*schedule, len(schedules))
+	for name, s := range schedules {
+		declared[name] = s
+	}
+	mu.RUnlock()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var due []model.JobSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_run_at <= ?", now).Find(&due).Error; err != nil {
+			return err
+		}
+
+		for _, row := range due {
+			s := declared[row.Name]
+			if s == nil {
+				// Declared by another version of the app
+				continue
+			}
+			if _, err := Enqueue(tx, s.jobType, s.payload, Options{UniqueKey: "schedule:" + s.name}); err != nil {
+				return err
+			}
+			if err := tx.Model(&row).Update("next_run_at", s.parsed.Next(now)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,266 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pollInterval is how often idle workers look for jobs and the scheduler
+// looks for due schedules
+const pollInterval = time.Second
+
+// maxBackoff caps the wait between retries
+const maxBackoff = time.Hour
+
+// Runner claims queued jobs and runs them with a fixed number of workers
+type Runner struct {
+	db     *gorm.DB
+	stop   chan struct{}
+	ctx    context.Context // Cancelled when shutdown runs out of time
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start stores the declared schedules, then starts the scheduler and the
+// workers. Register job types and declare schedules before calling it.
+func Start(db *gorm.DB, workers int) (*Runner, error) {
+	if err := syncSchedules(db, time.Now()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{db: db, stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+	r.wg.Add(1)
+	go r.schedule()
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return r, nil
+}
+
+// Stop stops claiming jobs and waits for running ones to finish. If ctx
+// ends first, running jobs are cancelled and Stop returns; their locks
+// expire and they are picked up again later.
+func (r *Runner) Stop(ctx context.Context) error {
+	close(r.stop)
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	defer r.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sleep waits for d and reports whether the runner is still running
+func (r *Runner) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// schedule enqueues runs of recurring jobs as they come due
+func (r *Runner) schedule() {
+	defer r.wg.Done()
+	for r.sleep(pollInterval) {
+		if err := enqueueDue(r.db, time.Now()); err != nil {
+			log.Println("Error scheduling jobs: ", err.Error())
+		}
+	}
+}
+
+// work runs jobs one after the other until the runner stops
+func (r *Runner) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		job, err := r.claim(time.Now())
+		if err != nil {
+			log.Println("Error claiming job: ", err.Error())
+		}
+		if job == nil {
+			if !r.sleep(pollInterval) {
+				return
+			}
+			continue
+		}
+		r.run(job)
+	}
+}
+
+// claim locks the next due job for this worker: a queued job whose time has
+// come, or a running job whose lock expired because its worker died or
+// timed out. It returns nil when there is nothing to do.
+func (r *Runner) claim(now time.Time) (*model.Job, error) {
+	types := registeredTypes()
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var job model.Job
+	var expired *model.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("type IN ?", types).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				model.JobQueued, now, model.JobRunning, now).
+			Order("run_at ASC, id ASC").Limit(1).
+			Find(&job).Error; err != nil || job.ID == 0 {
+			return err
+		}
+
+		// An expired lock used up an attempt without recording it
+		if job.Status == model.JobRunning && job.Attempts >= job.MaxAttempts {
+			err := tx.Model(&job).Updates(map[string]interface{}{
+				"status":       model.JobFailed,
+				"last_error":   "timed out",
+				"locked_until": nil,
+				"finished_at":  now,
+			}).Error
+			failed := job
+			expired = &failed
+			job.ID = 0
+			return err
+		}
+
+		lockedUntil := now.Add(lookup(job.Type).cfg.Timeout)
+		job.Status = model.JobRunning
+		job.Attempts++
+		job.LockedUntil = &lockedUntil
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"locked_until": job.LockedUntil,
+		}).Error
+	})
+	if err == nil && expired != nil {
+		failed(lookup(expired.Type), expired, errors.New("timed out"))
+	}
+	if err != nil || job.ID == 0 {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run runs a claimed job and records the outcome: success, a retry after a
+// backoff, or failure once it has run out of attempts
+func (r *Runner) run(job *model.Job) {
+	h := lookup(job.Type)
+	ctx, cancel := context.WithTimeout(r.ctx, h.cfg.Timeout)
+	err := call(ctx, h, job)
+	cancel()
+
+	now := time.Now()
+	updates := map[string]interface{}{"locked_until": nil}
+	switch {
+	case err == nil:
+		updates["status"] = model.JobSucceeded
+		updates["finished_at"] = now
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed: %v", job.ID, job.Type, err)
+		updates["status"] = model.JobFailed
+		updates["last_error"] = err.Error()
+		updates["finished_at"] = now
+	default:
+		log.Printf("Job %d (%s) failed, retrying: %v", job.ID, job.Type, err)
+		updates["status"] = model.JobQueued
+		updates["last_error"] = err.Error()
+		updates["run_at"] = now.Add(backoff(h.cfg.Backoff, job.Attempts))
+	}
+
+	// Leave the job alone if its lock expired and another worker took it
+	res := r.db.Model(&model.Job{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, model.JobRunning, job.Attempts).
+		Updates(updates)
+	if res.Error != nil {
+		log.Println("Error recording job result: ", res.Error.Error())
+		return
+	}
+	if res.RowsAffected > 0 && updates["status"] == model.JobFailed {
+		failed(h, job, err)
+	}
+}
+
+// failed calls the failure function of a job that has run out of attempts
+func failed(h *handler, job *model.Job, err error) {
+	if h == nil || h.fail == nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Failure function of job %d (%s) panicked: %v", job.ID, job.Type, p)
+		}
+	}()
+	h.fail([]byte(job.Payload), err)
+}
+
+// call runs a job's handler, turning a panic into an error
+func call(ctx context.Context, h *handler, job *model.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h.run(ctx, []byte(job.Payload))
+}
+
+// backoff returns the wait before retrying after the given attempt
+func backoff(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Retry queues a failed job to run again with a fresh set of attempts
+func Retry(db *gorm.DB, job *model.Job) error {
+	res := db.Model(&model.Job{}).Where("id = ? AND status = ?", job.ID, model.JobFailed).Updates(map[string]interface{}{
+		"status":      model.JobQueued,
+		"attempts":    0,
+		"run_at":      time.Now(),
+		"finished_at": nil,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("only failed jobs can be retried")
+	}
+	return db.First(job, job.ID).Error
+}
+
+// Prune deletes jobs with the given status that finished before the cutoff
+func Prune(db *gorm.DB, status string, before time.Time) (int64, error) {
+	res := db.Where("status = ? AND finished_at < ?", status, before).Delete(&model.Job{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File is a mailer that writes each message to a .eml file in Dir
+type File struct {
+	Dir string
+}
+
+// Send writes the message to a new file
+func (f *File) Send(msg Message) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(f.Dir, name), build("", msg), 0o644)
+}
+
+// sanitize keeps a string safe to use in a file name
+func sanitize(s string) string {
+	out := []rune(s)
+	for i, r := range out {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,81 @@
+// Package mailer renders and delivers emails. Locally the log or file
+// drivers stand in for a real SMTP server.
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	texttemplate "text/template"
+
+	"app/config"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+// Message is an email with a plain text and an HTML body
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Compose renders the named template pair (name.txt and name.html) into a
+// message
+func Compose(to, subject, name string, data interface{}) (Message, error) {
+	msg := Message{To: to, Subject: subject}
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return msg, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return msg, err
+	}
+	msg.Text, msg.HTML = text.String(), html.String()
+	return msg, nil
+}
+
+// FromConfig returns the mailer selected by MAIL_DRIVER: "smtp", "file"
+// (writes to MAIL_DIR) or "log" (the default)
+func FromConfig() Mailer {
+	switch strings.ToLower(config.Config("MAIL_DRIVER")) {
+	case "smtp":
+		return &SMTP{
+			Host:     config.Config("SMTP_HOST"),
+			Port:     config.Config("SMTP_PORT"),
+			Username: config.Config("SMTP_USERNAME"),
+			Password: config.Config("SMTP_PASSWORD"),
+			From:     config.Config("MAIL_FROM"),
+		}
+	case "file":
+		dir := config.Config("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return &File{Dir: dir}
+	}
+	return Log{}
+}
+
+// Log is a mailer that only logs messages
+type Log struct{}
+
+// Send logs the message
+func (Log) Send(msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"app/database"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Admin lets only admins through. It goes after Protected.
+func Admin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		var user model.User
+		if !ok || database.DB.Select("id", "is_admin").First(&user, userID).Error != nil || !user.IsAdmin {
+			return c.Status(fiber.StatusForbidden).
+				JSON(fiber.Map{"status": "error", "message": "Admin access required", "data": nil})
+		}
+		return c.Next()
+	}
+}
@@ -18,6 +18,17 @@ func Protected() fiber.Handler {
 	})
 }
 
+// ProtectedStream protect streaming routes. Browsers can't set headers on
+// EventSource requests, so the token may also be given as ?token=
+func ProtectedStream() fiber.Handler {
+	return jwtware.New(jwtware.Config{
+		SigningKey:     jwtware.SigningKey{Key: []byte(config.Config("SECRET"))},
+		TokenLookup:    "header:Authorization,query:token",
+		ErrorHandler:   jwtError,
+		SuccessHandler: jwtSuccessHandler,
+	})
+}
+
 func jwtError(c *fiber.Ctx, err error) error {
 	log.Println("Error in auth.go middleware: ", err.Error())
 	if err.Error() == "missing or malformed JWT" {
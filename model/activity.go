@@ -0,0 +1,29 @@
+package model
+
+import "gorm.io/gorm"
+
+// Activity resource types, besides the commentable ones
+const (
+	ResourceHabit = "habit"
+	ResourceList  = "list"
+)
+
+// Activity actions
+const (
+	ActivityCreated   = "created"
+	ActivityUpdated   = "updated"
+	ActivityCompleted = "completed"
+	ActivityReopened  = "reopened"
+	ActivityDeleted   = "deleted"
+)
+
+// Activity struct, one thing a user did to one of their records
+type Activity struct {
+	gorm.Model
+	UserID       uint   `gorm:"not null;index" json:"user_id"`
+	ResourceType string `gorm:"not null;size:20" json:"resource_type"`
+	ResourceID   uint   `gorm:"not null" json:"resource_id"`
+	GoalID       *uint  `gorm:"index" json:"goal_id"` // Goal the resource belongs to, decides who else can see it
+	Action       string `gorm:"not null;size:20" json:"action"`
+	Summary      string `json:"summary"` // Name or text of the resource at the time
+}
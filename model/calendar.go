@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Calendar feed scopes
+const (
+	CalendarAll   = "all"   // Goals, milestones, habits and due tasks
+	CalendarGoals = "goals" // Goals, milestones and habits only
+	CalendarTasks = "tasks" // Due tasks only
+	CalendarList  = "list"  // Due tasks of one list
+)
+
+// CalendarFeed struct, a secret iCalendar subscription URL for a user's
+// deadlines
+type CalendarFeed struct {
+	gorm.Model
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Name      string     `gorm:"size:100" json:"name"`
+	Token     string     `gorm:"not null;uniqueIndex;size:64" json:"token"`
+	Scope     string     `gorm:"not null;size:20;default:all" json:"scope"`
+	ListID    *uint      `gorm:"index" json:"list_id"` // Set for the list scope
+	RevokedAt *time.Time `json:"revoked_at"`
+}
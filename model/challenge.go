@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Challenge rules
+const (
+	ChallengeRuleHabit  = "habit"  // Score a point for every period with a habit check-in
+	ChallengeRuleTarget = "target" // Score a point for every day the daily target is reached
+)
+
+// Challenge struct
+type Challenge struct {
+	gorm.Model
+	Name        string    `gorm:"not null;size:255" json:"name"`
+	Description string    `json:"description"`
+	CreatorID   uint      `gorm:"not null" json:"creator_id"`
+	StartDate   time.Time `gorm:"type:date;not null" json:"start_date"`
+	EndDate     time.Time `gorm:"type:date;not null" json:"end_date"` // Inclusive
+	JoinCode    string    `gorm:"uniqueIndex;not null;size:16" json:"join_code"`
+	Rule        string    `gorm:"not null;size:20" json:"rule"`
+	Frequency   string    `gorm:"not null;size:20;default:daily" json:"frequency"` // Scoring periods; always daily for target challenges
+	Target      float64   `json:"target"`                                          // Daily target for target challenges
+	Unit        string    `gorm:"size:50" json:"unit"`
+}
+
+// ChallengeParticipant struct. Participants score from one of their own
+// habits (habit challenges) or goals (target challenges).
+type ChallengeParticipant struct {
+	gorm.Model
+	ChallengeID uint  `gorm:"not null;uniqueIndex:idx_challenge_participant" json:"challenge_id"`
+	UserID      uint  `gorm:"not null;uniqueIndex:idx_challenge_participant" json:"user_id"`
+	HabitID     *uint `json:"habit_id"`
+	GoalID      *uint `json:"goal_id"`
+}
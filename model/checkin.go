@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Check-in review statuses
+const (
+	ReviewPending   = "pending"
+	ReviewConfirmed = "confirmed"
+	ReviewCommented = "commented"
+)
+
+// CheckIn struct, one per goal per cadence period
+type CheckIn struct {
+	gorm.Model
+	GoalID        uint       `gorm:"not null;uniqueIndex:idx_check_in_period" json:"goal_id"`
+	UserID        uint       `gorm:"not null" json:"user_id"`
+	PeriodStart   time.Time  `gorm:"type:date;not null;uniqueIndex:idx_check_in_period" json:"period_start"`
+	Progress      string     `json:"progress"`
+	Blockers      string     `json:"blockers"`
+	Confidence    int        `gorm:"not null" json:"confidence"` // 1 (not at all) to 10 (certain)
+	ReviewStatus  string     `gorm:"not null;size:20;default:pending" json:"review_status"`
+	ReviewerID    *uint      `json:"reviewer_id"`
+	ReviewComment string     `json:"review_comment"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+}
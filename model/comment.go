@@ -0,0 +1,40 @@
+package model
+
+import "gorm.io/gorm"
+
+// Resources that can be commented on and reacted to
+const (
+	ResourceGoal    = "goal"
+	ResourceSubgoal = "subgoal"
+	ResourceTask    = "task"
+)
+
+// Comment struct
+type Comment struct {
+	gorm.Model
+	UserID       uint             `gorm:"not null" json:"user_id"`
+	ResourceType string           `gorm:"not null;size:20;index:idx_comment_resource" json:"resource_type"`
+	ResourceID   uint             `gorm:"not null;index:idx_comment_resource" json:"resource_id"`
+	ParentID     *uint            `gorm:"index" json:"parent_id"`
+	Body         string           `gorm:"not null" json:"body"`
+	Mentions     []CommentMention `gorm:"foreignKey:CommentID" json:"mentions"`
+	Username     string           `gorm:"-" json:"username"` // Not stored in DB, author's username
+	Replies      []Comment        `gorm:"-" json:"replies"`  // Not stored in DB, built when threading
+}
+
+// CommentMention struct
+type CommentMention struct {
+	ID        uint   `gorm:"primarykey" json:"-"`
+	CommentID uint   `gorm:"not null;index" json:"comment_id"`
+	UserID    uint   `gorm:"not null" json:"user_id"`
+	Username  string `gorm:"not null;size:50" json:"username"`
+}
+
+// Reaction struct
+type Reaction struct {
+	gorm.Model
+	UserID       uint   `gorm:"not null;uniqueIndex:idx_reaction" json:"user_id"`
+	ResourceType string `gorm:"not null;size:20;uniqueIndex:idx_reaction;index:idx_reaction_resource" json:"resource_type"`
+	ResourceID   uint   `gorm:"not null;uniqueIndex:idx_reaction;index:idx_reaction_resource" json:"resource_id"`
+	Emoji        string `gorm:"not null;size:32;uniqueIndex:idx_reaction" json:"emoji"`
+}
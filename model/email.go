@@ -0,0 +1,19 @@
+package model
+
+import "gorm.io/gorm"
+
+// Scheduled email kinds
+const (
+	EmailReminder = "reminder"
+	EmailHabits   = "habits"
+	EmailDigest   = "digest"
+)
+
+// EmailLog struct, records a scheduled email that was sent so it isn't sent
+// twice. Key identifies what it was about, e.g. a goal and deadline or a day.
+type EmailLog struct {
+	gorm.Model
+	UserID uint   `gorm:"not null;uniqueIndex:idx_email_log" json:"user_id"`
+	Kind   string `gorm:"not null;size:20;uniqueIndex:idx_email_log" json:"kind"`
+	Key    string `gorm:"not null;size:100;uniqueIndex:idx_email_log" json:"key"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResourceExport is the resource type of notifications about data exports
+const ResourceExport = "export"
+
+// Data export statuses
+const (
+	ExportQueued    = "queued"
+	ExportRunning   = "running"
+	ExportSucceeded = "succeeded"
+	ExportFailed    = "failed"
+)
+
+// DataExport struct, an archive of all of a user's data built in the
+// background and downloaded through a secret link until it expires
+type DataExport struct {
+	gorm.Model
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Status     string     `gorm:"not null;size:20;default:queued" json:"status"`
+	Token      string     `gorm:"not null;size:64;uniqueIndex" json:"-"` // Secret in the download link
+	Archive    []byte     `json:"-"`
+	Size       int        `gorm:"not null;default:0" json:"size"` // Archive size in bytes
+	Error      string     `json:"error,omitempty"`                // Why building the archive failed
+	FinishedAt *time.Time `json:"finished_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // The download link stops working then
+}
@@ -0,0 +1,10 @@
+package model
+
+import "gorm.io/gorm"
+
+// Follow struct, one user following another's public profile
+type Follow struct {
+	gorm.Model
+	FollowerID uint `gorm:"not null;uniqueIndex:idx_follow" json:"follower_id"`
+	FolloweeID uint `gorm:"not null;uniqueIndex:idx_follow;index" json:"followee_id"`
+}
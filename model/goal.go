@@ -9,8 +9,10 @@ import (
 type Goal struct {
 	gorm.Model
 	UserID          uint            `gorm:"not null" json:"user_id"`
+	GroupID         *uint           `gorm:"index" json:"group_id"` // Set for group goals shared by a group's members
 	Name            string          `gorm:"not null;size:255" json:"name"`
 	Deadline        time.Time       `json:"deadline"`
+	Target          float64         `json:"target"` // Numeric target per member, e.g. for group goals
 	Subgoals        []Subgoal       `gorm:"foreignKey:GoalID" json:"subgoals"`
 	Habits          []Habit         `gorm:"foreignKey:GoalID" json:"habits"`
 	Description     string          `json:"description"`
@@ -19,21 +21,65 @@ type Goal struct {
 	Resources       string          `json:"resources"`
 	Alignment       string          `json:"alignment"`
 	Completed       bool            `gorm:"default:false" json:"completed"`
+	Visibility      string          `gorm:"not null;size:20;default:private" json:"visibility"`
+	CheckInCadence  int             `gorm:"not null;default:7" json:"check_in_cadence"` // Days between check-ins
+	CountTasks      bool            `gorm:"default:false" json:"count_tasks"`           // Count linked tasks toward progress
+	Tasks           []Task          `gorm:"foreignKey:GoalID" json:"tasks"`
 	SubgoalProgress map[string]bool `gorm:"-" json:"subgoal_progress"` // Not stored in DB, but handled in code
+	Progress        float64         `gorm:"-" json:"progress"`         // Not stored in DB, computed on read
+	CommentCount    int             `gorm:"-" json:"comment_count"`    // Not stored in DB, computed on read
 }
 
+// Goal visibility settings
+const (
+	VisibilityPrivate  = "private"
+	VisibilityPartners = "partners"
+	VisibilityPublic   = "public"
+)
+
 // Subgoal struct
 type Subgoal struct {
 	gorm.Model
-	GoalID    uint   `gorm:"not null" json:"goal_id"`
-	Name      string `gorm:"not null;size:255" json:"name"`
-	Completed bool   `gorm:"default:false" json:"completed"`
+	GoalID       uint       `gorm:"not null" json:"goal_id"`
+	Name         string     `gorm:"not null;size:255" json:"name"`
+	Completed    bool       `gorm:"default:false" json:"completed"`
+	Deadline     *time.Time `json:"deadline"`               // Milestone date, optional
+	CommentCount int        `gorm:"-" json:"comment_count"` // Not stored in DB, computed on read
 }
 
 // Habit struct
 type Habit struct {
 	gorm.Model
-	GoalID    uint   `gorm:"not null" json:"goal_id"`
-	Name      string `gorm:"not null;size:255" json:"name"`
-	Frequency string `json:"frequency"`
+	GoalID        uint   `gorm:"not null" json:"goal_id"`
+	Name          string `gorm:"not null;size:255" json:"name"`
+	Frequency     string `json:"frequency"`
+	CurrentStreak int    `gorm:"-" json:"current_streak"` // Not stored in DB, computed from check-ins
+	LongestStreak int    `gorm:"-" json:"longest_streak"` // Not stored in DB, computed from check-ins
+}
+
+// GoalProgress returns the share of a goal's subgoals that are completed,
+// counting completed linked tasks as well when the goal opts in
+func GoalProgress(goal *Goal) float64 {
+	total, done := 0, 0
+	for _, subgoal := range goal.Subgoals {
+		total++
+		if subgoal.Completed {
+			done++
+		}
+	}
+	if goal.CountTasks {
+		for _, task := range goal.Tasks {
+			total++
+			if task.Completed {
+				done++
+			}
+		}
+	}
+	if total == 0 {
+		if goal.Completed {
+			return 1
+		}
+		return 0
+	}
+	return float64(done) / float64(total)
 }
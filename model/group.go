@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles a user can have in a group
+const (
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+// ResourceGroup is the resource type of notifications about groups
+const ResourceGroup = "group"
+
+// Group struct
+type Group struct {
+	gorm.Model
+	Name        string `gorm:"not null;size:255" json:"name"`
+	Description string `json:"description"`
+	OwnerID     uint   `gorm:"not null" json:"owner_id"`
+}
+
+// GroupMember struct
+type GroupMember struct {
+	gorm.Model
+	GroupID uint   `gorm:"not null;uniqueIndex:idx_group_member" json:"group_id"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_group_member" json:"user_id"`
+	Role    string `gorm:"not null;size:20" json:"role"`
+}
+
+// GroupInvitation struct, a pending offer to join a group. Statuses are the
+// same as for list invitations.
+type GroupInvitation struct {
+	gorm.Model
+	GroupID   uint   `gorm:"not null;index" json:"group_id"`
+	Group     *Group `json:"group,omitempty"`
+	InviterID uint   `gorm:"not null" json:"inviter_id"`
+	InviteeID uint   `gorm:"not null;index" json:"invitee_id"`
+	Role      string `gorm:"not null;size:20" json:"role"`
+	Status    string `gorm:"not null;size:20;default:pending" json:"status"`
+}
+
+// ProgressEntry struct, a user's contribution toward a numeric goal
+type ProgressEntry struct {
+	gorm.Model
+	GoalID uint      `gorm:"not null;index" json:"goal_id"`
+	UserID uint      `gorm:"not null;index" json:"user_id"`
+	Amount float64   `gorm:"not null" json:"amount"`
+	Note   string    `json:"note"`
+	Date   time.Time `gorm:"type:date;not null" json:"date"`
+}
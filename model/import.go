@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResourceImport is the resource type of notifications about imports
+const ResourceImport = "import"
+
+// Import statuses
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportFailed    = "failed"
+)
+
+// ImportRowError is a task in an import file that couldn't be imported, or
+// only in part
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// Import struct, an export from another tool that is imported in the
+// background. The idempotency key keeps the same file from being imported
+// twice.
+type Import struct {
+	gorm.Model
+	UserID         uint             `gorm:"not null;uniqueIndex:idx_import_user_key" json:"user_id"`
+	IdempotencyKey string           `gorm:"not null;size:255;uniqueIndex:idx_import_user_key" json:"idempotency_key"`
+	Source         string           `gorm:"not null;size:20" json:"source"` // "todoist", "trello" or "mstodo"
+	FileName       string           `gorm:"size:255" json:"file_name"`
+	Data           []byte           `json:"-"` // The uploaded file, cleared once it has been imported
+	Status         string           `gorm:"not null;size:20;default:queued" json:"status"`
+	Total          int              `gorm:"not null;default:0" json:"total"`     // Tasks in the file, including subtasks
+	Processed      int              `gorm:"not null;default:0" json:"processed"` // Tasks created so far
+	ListIDs        []uint           `gorm:"serializer:json" json:"list_ids"`
+	Errors         []ImportRowError `gorm:"serializer:json" json:"errors"`
+	Error          string           `json:"error,omitempty"` // Why the import failed
+	FinishedAt     *time.Time       `json:"finished_at"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job struct, a unit of background work in the persistent queue
+type Job struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Type        string     `gorm:"not null;size:100;index" json:"type"`
+	Payload     string     `gorm:"type:text;not null;default:'{}'" json:"payload"` // JSON
+	Status      string     `gorm:"not null;size:20;default:queued;index:idx_job_claim,priority:1" json:"status"`
+	RunAt       time.Time  `gorm:"not null;index:idx_job_claim,priority:2" json:"run_at"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	LockedUntil *time.Time `json:"locked_until"` // A running job whose lock expires is picked up again
+	LastError   string     `gorm:"type:text" json:"last_error"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	// At most one unfinished job exists per unique key
+	UniqueKey *string `gorm:"size:200;uniqueIndex:idx_job_unique_key,where:finished_at IS NULL" json:"unique_key"`
+}
+
+// JobSchedule struct, when a recurring job is next due. Schedules are
+// declared in code; the table lets instances agree on who enqueues a run.
+type JobSchedule struct {
+	Name      string    `gorm:"primarykey;size:100" json:"name"`
+	Spec      string    `gorm:"not null;size:100" json:"spec"`
+	NextRunAt time.Time `gorm:"not null" json:"next_run_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotificationNudge           = "nudge"
+	NotificationDeadline        = "deadline"         // A goal's deadline is coming up
+	NotificationComment         = "comment"          // Someone commented on your goal or task
+	NotificationMention         = "mention"          // Someone mentioned you in a comment
+	NotificationListInvitation  = "list_invitation"  // Someone invited you to a list
+	NotificationGroupInvitation = "group_invitation" // Someone invited you to a group
+	NotificationListChange      = "list_change"      // Someone changed a task in a list you share
+	NotificationPartner         = "partner"          // Partner requests and acceptances
+	NotificationCheckInReview   = "check_in_review"  // A partner reviewed your check-in
+	NotificationStake           = "stake"            // Claims to referee and stake outcomes
+	NotificationFollow          = "follow"           // Someone followed you
+	NotificationWebhook         = "webhook"          // One of your webhooks was disabled
+	NotificationImport          = "import"           // An import from another tool finished
+	NotificationExport          = "export"           // Your data export is ready
+)
+
+// NotificationTypes lists every notification type users can turn off.
+// Nudges are turned off with the user's AllowNudges setting instead.
+var NotificationTypes = []string{
+	NotificationDeadline,
+	NotificationComment,
+	NotificationMention,
+	NotificationListInvitation,
+	NotificationGroupInvitation,
+	NotificationListChange,
+	NotificationPartner,
+	NotificationCheckInReview,
+	NotificationStake,
+	NotificationFollow,
+	NotificationWebhook,
+	NotificationImport,
+	NotificationExport,
+}
+
+// Notification struct, an entry in a user's in-app inbox
+type Notification struct {
+	gorm.Model
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Type         string     `gorm:"not null;size:30" json:"type"`
+	ActorID      *uint      `json:"actor_id"` // User who caused it, if any
+	ResourceType string     `gorm:"size:20" json:"resource_type"`
+	ResourceID   uint       `json:"resource_id"`
+	Message      string     `json:"message"`
+	DeliverAt    time.Time  `gorm:"not null;index" json:"deliver_at"` // Held back until the recipient's quiet hours end
+	ReadAt       *time.Time `json:"read_at"`
+}
+
+// Nudge struct, a reminder one partner sent another
+type Nudge struct {
+	gorm.Model
+	SenderID     uint   `gorm:"not null;index:idx_nudge_pair" json:"sender_id"`
+	RecipientID  uint   `gorm:"not null;index:idx_nudge_pair" json:"recipient_id"`
+	ResourceType string `gorm:"not null;size:20" json:"resource_type"`
+	ResourceID   uint   `gorm:"not null" json:"resource_id"`
+	Message      string `json:"message"`
+}
+
+// NotificationPreference struct, whether a user wants notifications of a type
+type NotificationPreference struct {
+	gorm.Model
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_notification_preference" json:"user_id"`
+	Type    string `gorm:"not null;size:30;uniqueIndex:idx_notification_preference" json:"type"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+}
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// OutboxEvent struct, a domain event written in the same transaction as the
+// change it describes and relayed to subscribers afterwards
+type OutboxEvent struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	Type         string    `gorm:"not null;size:100" json:"type"` // e.g. "task.completed"
+	ActorID      uint      `gorm:"not null" json:"actor_id"`
+	ResourceType string    `gorm:"not null;size:20" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null" json:"resource_id"`
+	Payload      string    `gorm:"type:text;not null;default:'{}'" json:"payload"` // JSON
+}
+
+// OutboxCursor struct, how far a subscriber has got through the outbox
+type OutboxCursor struct {
+	Subscriber  string     `gorm:"primarykey;size:100" json:"subscriber"`
+	LastEventID uint       `gorm:"not null;default:0" json:"last_event_id"`
+	Failures    int        `gorm:"not null;default:0" json:"failures"` // In a row, on the event after LastEventID
+	RetryAt     *time.Time `json:"retry_at"`
+	LastError   string     `gorm:"type:text" json:"last_error"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// OutboxFailure struct, an event a subscriber gave up on after failing on it
+// too often in a row
+type OutboxFailure struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	Subscriber string    `gorm:"not null;size:100;index" json:"subscriber"`
+	EventID    uint      `gorm:"not null" json:"event_id"`
+	Error      string    `gorm:"type:text" json:"error"`
+}
+
+// ProcessedEvent struct, an event a consumer has already handled
+type ProcessedEvent struct {
+	Consumer  string    `gorm:"primarykey;size:100"`
+	EventID   uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Partnership statuses
+const (
+	PartnershipPending  = "pending"
+	PartnershipAccepted = "accepted"
+	PartnershipBlocked  = "blocked"
+)
+
+// Partnership struct. There is at most one partnership per pair of users,
+// whichever of them sent the request.
+type Partnership struct {
+	gorm.Model
+	RequesterID uint   `gorm:"not null;index" json:"requester_id"`
+	AddresseeID uint   `gorm:"not null;index" json:"addressee_id"`
+	Status      string `gorm:"not null;size:20;default:pending" json:"status"`
+	BlockedByID *uint  `json:"blocked_by_id,omitempty"`
+}
+
+// HabitCheckIn struct, one per habit per day
+type HabitCheckIn struct {
+	gorm.Model
+	HabitID uint      `gorm:"not null;uniqueIndex:idx_habit_check_in_day" json:"habit_id"`
+	Date    time.Time `gorm:"type:date;not null;uniqueIndex:idx_habit_check_in_day" json:"date"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+// HabitPeriod returns the start of the period containing t for a habit's
+// frequency: weeks start on Monday, anything but "weekly" or "monthly"
+// counts as daily
+func HabitPeriod(frequency string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch frequency {
+	case "weekly":
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "monthly":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// HabitFrequency returns the frequency a habit's periods follow: "weekly",
+// "monthly" or "daily"
+func HabitFrequency(frequency string) string {
+	switch frequency {
+	case "weekly", "monthly":
+		return frequency
+	}
+	return "daily"
+}
+
+// NextHabitPeriod returns the start of the period following the given one
+func NextHabitPeriod(frequency string, period time.Time) time.Time {
+	switch frequency {
+	case "weekly":
+		return period.AddDate(0, 0, 7)
+	case "monthly":
+		return period.AddDate(0, 1, 0)
+	}
+	return period.AddDate(0, 0, 1)
+}
+
+// HabitStreaks counts consecutive periods with at least one check-in. The
+// current streak stays alive until the period after the last check-in ends.
+// dates must be sorted in ascending order.
+func HabitStreaks(frequency string, dates []time.Time, now time.Time) (current, longest int) {
+	var last time.Time
+	run := 0
+	for _, date := range dates {
+		period := HabitPeriod(frequency, date)
+		switch {
+		case run > 0 && period.Equal(last):
+			continue
+		case run > 0 && period.Equal(NextHabitPeriod(frequency, last)):
+			run++
+		default:
+			run = 1
+		}
+		last = period
+		if run > longest {
+			longest = run
+		}
+	}
+
+	if run > 0 {
+		thisPeriod := HabitPeriod(frequency, now)
+		if last.Equal(thisPeriod) || NextHabitPeriod(frequency, last).Equal(thisPeriod) {
+			current = run
+		}
+	}
+	return current, longest
+}
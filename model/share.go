@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareLink struct, an unguessable link to a read-only view of a goal
+type ShareLink struct {
+	gorm.Model
+	GoalID    uint       `gorm:"not null;index" json:"goal_id"`
+	UserID    uint       `gorm:"not null" json:"user_id"`
+	Token     string     `gorm:"not null;uniqueIndex;size:64" json:"token"`
+	ExpiresAt *time.Time `json:"expires_at"` // Never expires when nil
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// ShareSettings struct, which fields of a goal its share links expose. The
+// goal's name and completed status are always shown.
+type ShareSettings struct {
+	gorm.Model
+	GoalID          uint `gorm:"not null;uniqueIndex" json:"goal_id"`
+	ShowDescription bool `gorm:"not null" json:"show_description"`
+	ShowDeadline    bool `gorm:"not null" json:"show_deadline"`
+	ShowProgress    bool `gorm:"not null" json:"show_progress"`
+	ShowSubgoals    bool `gorm:"not null" json:"show_subgoals"`
+	ShowHabits      bool `gorm:"not null" json:"show_habits"`
+}
+
+// DefaultShareSettings returns the settings used for goals that have none
+func DefaultShareSettings(goalID uint) ShareSettings {
+	return ShareSettings{
+		GoalID:          goalID,
+		ShowDescription: true,
+		ShowProgress:    true,
+		ShowSubgoals:    true,
+	}
+}
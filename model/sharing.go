@@ -0,0 +1,36 @@
+package model
+
+import "gorm.io/gorm"
+
+// Roles a user can have on a shared task list
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// ListMember struct
+type ListMember struct {
+	gorm.Model
+	TaskListID uint   `gorm:"not null;uniqueIndex:idx_list_member" json:"task_list_id"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_list_member" json:"user_id"`
+	Role       string `gorm:"not null;size:20" json:"role"`
+}
+
+// Invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// ListInvitation struct
+type ListInvitation struct {
+	gorm.Model
+	TaskListID uint      `gorm:"not null;index" json:"task_list_id"`
+	TaskList   *TaskList `json:"task_list,omitempty"`
+	InviterID  uint      `gorm:"not null" json:"inviter_id"`
+	InviteeID  uint      `gorm:"not null;index" json:"invitee_id"`
+	Role       string    `gorm:"not null;size:20" json:"role"`
+	Status     string    `gorm:"not null;size:20;default:pending" json:"status"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stake statuses
+const (
+	StakeActive    = "active"    // Goal still open
+	StakeClaimed   = "claimed"   // Completion claimed, waiting on the referee
+	StakeKept      = "kept"      // Completion approved, nothing forfeited
+	StakeForfeited = "forfeited" // Deadline missed or completion rejected
+)
+
+// Forfeit reasons
+const (
+	ForfeitDeadlineMissed  = "deadline_missed"
+	ForfeitRefereeRejected = "referee_rejected"
+)
+
+// Stake struct, what the owner loses if a goal isn't completed in time
+type Stake struct {
+	gorm.Model
+	GoalID         uint       `gorm:"not null;index" json:"goal_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	RefereeID      uint       `gorm:"not null;index" json:"referee_id"`
+	Amount         float64    `gorm:"not null" json:"amount"`
+	Unit           string     `gorm:"not null;size:20;default:points" json:"unit"` // "points" or a currency code
+	Status         string     `gorm:"not null;size:20;default:active" json:"status"`
+	ClaimedAt      *time.Time `json:"claimed_at"`
+	DisputeUntil   *time.Time `json:"dispute_until"` // Referee can reject the claim until then
+	RefereeComment string     `json:"referee_comment"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+// LedgerEntry struct, a forfeited stake
+type LedgerEntry struct {
+	gorm.Model
+	UserID   uint    `gorm:"not null;index" json:"user_id"`
+	StakeID  uint    `gorm:"not null" json:"stake_id"`
+	GoalID   uint    `gorm:"not null" json:"goal_id"`
+	GoalName string  `json:"goal_name"` // Kept so the history survives the goal being purged
+	Amount   float64 `gorm:"not null" json:"amount"`
+	Unit     string  `gorm:"not null;size:20" json:"unit"`
+	Reason   string  `gorm:"not null;size:30" json:"reason"`
+}
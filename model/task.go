@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // List struct
 type TaskList struct {
@@ -8,12 +12,28 @@ type TaskList struct {
 	UserID uint   `gorm:"not null" json:"user_id"`
 	Name   string `gorm:"not null" json:"name"`
 	Tasks  []Task `gorm:"foreignKey:TaskListID" json:"tasks"`
+	Role   string `gorm:"-" json:"role,omitempty"` // Caller's role, filled in on read
 }
 
 // Task struct
 type Task struct {
 	gorm.Model
-	TaskListID uint   `gorm:"not null" json:"task_list_id"`
-	Text       string `gorm:"not null" json:"text"`
-	Completed  bool   `gorm:"default:false" json:"completed"`
+	TaskListID    uint       `gorm:"not null" json:"task_list_id"`
+	Text          string     `gorm:"not null" json:"text"`
+	Completed     bool       `gorm:"default:false" json:"completed"`
+	CompletedByID *uint      `json:"completed_by_id"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	GoalID        *uint      `gorm:"index" json:"goal_id"`
+	SubgoalID     *uint      `gorm:"index" json:"subgoal_id"`
+	DueDate       *time.Time `json:"due_date"`
+	ParentID      *uint      `gorm:"index" json:"parent_id"` // Parent task of a subtask, in the same list
+	Labels        []Label    `gorm:"many2many:task_labels;" json:"labels"`
+	CommentCount  int        `gorm:"-" json:"comment_count"` // Not stored in DB, computed on read
+}
+
+// Label struct
+type Label struct {
+	gorm.Model
+	UserID uint   `gorm:"not null;uniqueIndex:idx_label_user_name" json:"user_id"`
+	Name   string `gorm:"not null;size:50;uniqueIndex:idx_label_user_name" json:"name"`
 }
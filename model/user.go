@@ -12,4 +12,17 @@ type User struct {
 	Occupation string     `json:"occupation"`
 	About      string     `json:"about"`
 	TaskLists  []TaskList `gorm:"foreignKey:UserID" json:"lists"`
+	IsAdmin    bool       `gorm:"not null;default:false" json:"-"` // Only set directly in the database
+
+	// Settings
+	Timezone        string `gorm:"not null;size:64;default:UTC" json:"timezone"` // IANA name, e.g. "Europe/Berlin"
+	QuietHoursStart *int   `json:"quiet_hours_start"`                            // Local hour (0-23), nil for no quiet hours
+	QuietHoursEnd   *int   `json:"quiet_hours_end"`
+	AllowNudges     bool   `gorm:"not null;default:true" json:"allow_nudges"`
+
+	// Email preferences
+	EmailReminders    bool `gorm:"not null;default:true" json:"email_reminders"`
+	ReminderLeadHours int  `gorm:"not null;default:24" json:"reminder_lead_hours"` // How long before a deadline to remind
+	EmailHabits       bool `gorm:"not null;default:true" json:"email_habits"`
+	EmailDigest       bool `gorm:"not null;default:true" json:"email_digest"`
 }
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResourceWebhook is the resource type of notifications about webhooks
+const ResourceWebhook = "webhook"
+
+// Webhook event types
+const (
+	WebhookTaskCompleted  = "task.completed"
+	WebhookGoalCompleted  = "goal.completed"
+	WebhookHabitCheckedIn = "habit.checked_in"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{WebhookTaskCompleted, WebhookGoalCompleted, WebhookHabitCheckedIn}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook struct, a user's endpoint that receives their events
+type Webhook struct {
+	gorm.Model
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	URL          string     `gorm:"not null;size:2048" json:"url"`
+	Description  string     `gorm:"size:200" json:"description"`
+	Events       []string   `gorm:"serializer:json;not null" json:"events"`
+	Secret       string     `gorm:"not null;size:100" json:"-"` // Signs the payloads, only shown when the webhook is created
+	Active       bool       `gorm:"not null;default:true" json:"active"`
+	FailureCount int        `gorm:"not null;default:0" json:"failure_count"` // Failed attempts since the last success
+	DisabledAt   *time.Time `json:"disabled_at"`                             // Set when disabled for failing too often
+}
+
+// Subscribes reports whether the webhook receives the event
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery struct, one event queued for a webhook and the outcome of
+// its latest attempt
+type WebhookDelivery struct {
+	gorm.Model
+	WebhookID     uint       `gorm:"not null;index" json:"webhook_id"`
+	Event         string     `gorm:"not null;size:50" json:"event"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"not null;size:20;default:pending;index" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	ResponseCode  int        `json:"response_code"` // 0 when no response was received
+	ResponseBody  string     `gorm:"type:text" json:"response_body"`
+	Error         string     `json:"error"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+}
@@ -0,0 +1,94 @@
+// Package outbox delivers domain events reliably. Handlers write events
+// with Emit in the same transaction as the change they describe, so an
+// event exists exactly when its change was committed. A relay then passes
+// the events to every subscriber in order, at least once.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// emitLock is the advisory lock that orders outbox writes. It is held until
+// the writing transaction ends, so events commit in ID order and the relay
+// never passes over an event that commits late.
+const emitLock = 7243011
+
+// Handler handles one event. Returning an error stops the subscriber at
+// that event until it succeeds.
+type Handler func(ctx context.Context, event *model.OutboxEvent) error
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[string]Handler)
+)
+
+// Subscribe registers a handler for every event under a stable name, which
+// keys its position in the outbox. Subscribe before the relay starts.
+func Subscribe(name string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers[name] = h
+}
+
+// subscriber returns the handler registered under a name
+func subscriber(name string) Handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return subscribers[name]
+}
+
+// subscriberNames returns the names of the registered subscribers
+func subscriberNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(subscribers))
+	for name := range subscribers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Emit writes an event to the outbox. It must run inside the transaction
+// that makes the change, as late as possible since it serializes commits.
+func Emit(tx *gorm.DB, eventType string, actorID uint, resourceType string, resourceID uint, payload interface{}) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", emitLock).Error; err != nil {
+		return err
+	}
+	return tx.Create(&model.OutboxEvent{
+		Type:         eventType,
+		ActorID:      actorID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Payload:      string(raw),
+	}).Error
+}
+
+// Decode decodes an event's payload
+func Decode(event *model.OutboxEvent, v interface{}) error {
+	return json.Unmarshal([]byte(event.Payload), v)
+}
+
+// Once makes a consumer idempotent: it runs fn in a transaction together
+// with recording that the consumer handled the event, and skips fn if the
+// event was handled before. Redelivered events then change nothing.
+func Once(db *gorm.DB, consumer string, eventID uint, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.ProcessedEvent{Consumer: consumer, EventID: eventID})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return fn(tx)
+	})
+}
@@ -0,0 +1,236 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// pollInterval is how often the relay looks for new events
+	pollInterval = 500 * time.Millisecond
+	// batchSize is how many events a subscriber is handed per round
+	batchSize = 100
+	// handlerTimeout is how long a subscriber may take for one event
+	handlerTimeout = 30 * time.Second
+	// maxRetryDelay caps the wait before a failing subscriber tries again
+	maxRetryDelay = 5 * time.Minute
+	// maxFailures is how often a subscriber fails on the same event in a row
+	// before the event is recorded as failed and skipped
+	maxFailures = 10
+)
+
+// Relay passes outbox events on to the subscribers, each at its own pace
+type Relay struct {
+	db     *gorm.DB
+	stop   chan struct{}
+	ctx    context.Context // Cancelled when shutdown runs out of time
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// StartRelay starts relaying events to the registered subscribers. A
+// subscriber registered for the first time starts at the end of the outbox:
+// it gets the events emitted from then on.
+func StartRelay(db *gorm.DB) (*Relay, error) {
+	var last uint
+	if err := db.Model(&model.OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Relay{db: db, stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+	for _, name := range subscriberNames() {
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.OutboxCursor{Subscriber: name, LastEventID: last}).Error; err != nil {
+			cancel()
+			return nil, err
+		}
+		r.wg.Add(1)
+		go r.run(name)
+	}
+	return r, nil
+}
+
+// Stop stops relaying once the events being handled are done. If ctx ends
+// first, the handlers are cancelled; those events are relayed again later.
+func (r *Relay) Stop(ctx context.Context) error {
+	close(r.stop)
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	defer r.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run relays events to one subscriber until the relay stops
+func (r *Relay) run(name string) {
+	defer r.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-timer.C:
+		}
+
+		more, err := r.relay(name, time.Now())
+		if err != nil {
+			log.Printf("Error relaying events to %s: %v", name, err)
+		}
+		if more {
+			timer.Reset(0)
+		} else {
+			timer.Reset(pollInterval)
+		}
+	}
+}
+
+// relay hands the subscriber its next batch of events in order, then moves
+// its cursor past the ones it handled. The cursor stays locked meanwhile,
+// so every subscriber is served by one instance at a time. A failing event
+// is retried with a growing delay, and skipped after maxFailures attempts so
+// it can't hold up the rest. It reports whether more events may be waiting.
+func (r *Relay) relay(name string, now time.Time) (bool, error) {
+	h := subscriber(name)
+	more := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var cursor model.OutboxCursor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("subscriber = ? AND (retry_at IS NULL OR retry_at <= ?)", name, now).
+			Limit(1).Find(&cursor).Error; err != nil || cursor.Subscriber == "" {
+			return err
+		}
+
+		var events []model.OutboxEvent
+		if err := tx.Where("id > ?", cursor.LastEventID).Order("id ASC").Limit(batchSize).
+			Find(&events).Error; err != nil || len(events) == 0 {
+			return err
+		}
+
+		res := handleBatch(cursor, events, now, func(event *model.OutboxEvent) error {
+			ctx, cancel := context.WithTimeout(r.ctx, handlerTimeout)
+			defer cancel()
+			return call(ctx, h, event)
+		})
+		if res.skipped != nil {
+			if err := tx.Create(res.skipped).Error; err != nil {
+				return err
+			}
+			log.Printf("Subscriber %s skipped event %d after %d failures", name, res.skipped.EventID, maxFailures)
+		}
+		more = res.more
+		return tx.Model(&model.OutboxCursor{}).Where("subscriber = ?", name).Updates(res.updates).Error
+	})
+	return more, err
+}
+
+// batchResult is what handing a subscriber a batch of events comes to
+type batchResult struct {
+	updates map[string]interface{} // Cursor columns to save
+	skipped *model.OutboxFailure   // Set when a failing event is given up on
+	more    bool                   // Whether more events may be waiting
+}
+
+// handleBatch runs handle on the events in order until one fails, and works
+// out where the subscriber's cursor goes next
+func handleBatch(cursor model.OutboxCursor, events []model.OutboxEvent, now time.Time, handle func(*model.OutboxEvent) error) batchResult {
+	start := cursor.LastEventID
+	var (
+		failure error
+		failed  uint
+	)
+	for i := range events {
+		if failure = handle(&events[i]); failure != nil {
+			failed = events[i].ID
+			break
+		}
+		cursor.LastEventID = events[i].ID
+	}
+
+	res := batchResult{updates: map[string]interface{}{"last_event_id": cursor.LastEventID}}
+	if failure == nil {
+		res.updates["failures"] = 0
+		res.updates["retry_at"] = nil
+		res.updates["last_error"] = ""
+		res.more = len(events) == batchSize
+		return res
+	}
+
+	if cursor.LastEventID != start {
+		cursor.Failures = 0
+	}
+	cursor.Failures++
+	log.Printf("Subscriber %s failed on event %d: %v", cursor.Subscriber, failed, failure)
+	res.updates["last_error"] = failure.Error()
+	if cursor.Failures >= maxFailures {
+		res.skipped = &model.OutboxFailure{Subscriber: cursor.Subscriber, EventID: failed, Error: failure.Error()}
+		res.updates["last_event_id"] = failed
+		res.updates["failures"] = 0
+		res.updates["retry_at"] = nil
+		res.more = true
+	} else {
+		res.updates["failures"] = cursor.Failures
+		res.updates["retry_at"] = now.Add(retryDelay(cursor.Failures))
+	}
+	return res
+}
+
+// call runs a subscriber, turning a panic into an error
+func call(ctx context.Context, h Handler, event *model.OutboxEvent) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, event)
+}
+
+// retryDelay returns the wait after the given number of failures in a row
+func retryDelay(failures int) time.Duration {
+	d := time.Second
+	for i := 1; i < failures && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// Prune deletes events created before the cutoff that every subscriber has
+// handled, and the consumer and failure records of events that old
+func Prune(db *gorm.DB, before time.Time) error {
+	query := db.Where("created_at < ?", before)
+	if names := subscriberNames(); len(names) > 0 {
+		var handled uint
+		if err := db.Model(&model.OutboxCursor{}).Where("subscriber IN ?", names).
+			Select("COALESCE(MIN(last_event_id), 0)").Scan(&handled).Error; err != nil {
+			return err
+		}
+		query = query.Where("id <= ?", handled)
+	}
+	if err := query.Delete(&model.OutboxEvent{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("created_at < ?", before).Delete(&model.OutboxFailure{}).Error; err != nil {
+		return err
+	}
+	return db.Where("created_at < ?", before).Delete(&model.ProcessedEvent{}).Error
+}
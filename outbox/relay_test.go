@@ -0,0 +1,139 @@
+package outbox
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"app/model"
+)
+
+func TestHandleBatch(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	errBoom := errors.New("boom")
+	events := func(ids ...uint) []model.OutboxEvent {
+		var out []model.OutboxEvent
+		for _, id := range ids {
+			out = append(out, model.OutboxEvent{ID: id})
+		}
+		return out
+	}
+	failOn := func(id uint) func(*model.OutboxEvent) error {
+		return func(event *model.OutboxEvent) error {
+			if event.ID == id {
+				return errBoom
+			}
+			return nil
+		}
+	}
+	full := make([]uint, batchSize)
+	for i := range full {
+		full[i] = uint(i + 1)
+	}
+
+	tests := []struct {
+		name    string
+		cursor  model.OutboxCursor
+		events  []model.OutboxEvent
+		handle  func(*model.OutboxEvent) error
+		want    map[string]interface{}
+		skipped *model.OutboxFailure
+		more    bool
+	}{
+		{
+			name:   "all handled",
+			cursor: model.OutboxCursor{Subscriber: "s", LastEventID: 4, Failures: 2},
+			events: events(5, 6, 7),
+			handle: failOn(0),
+			want:   map[string]interface{}{"last_event_id": uint(7), "failures": 0, "retry_at": nil, "last_error": ""},
+		},
+		{
+			name:   "full batch",
+			cursor: model.OutboxCursor{Subscriber: "s"},
+			events: events(full...),
+			handle: failOn(0),
+			want:   map[string]interface{}{"last_event_id": uint(batchSize), "failures": 0, "retry_at": nil, "last_error": ""},
+			more:   true,
+		},
+		{
+			name:   "first failure",
+			cursor: model.OutboxCursor{Subscriber: "s", LastEventID: 4},
+			events: events(5, 6, 7),
+			handle: failOn(5),
+			want:   map[string]interface{}{"last_event_id": uint(4), "failures": 1, "retry_at": now.Add(time.Second), "last_error": "boom"},
+		},
+		{
+			name:   "failing again",
+			cursor: model.OutboxCursor{Subscriber: "s", LastEventID: 4, Failures: 3},
+			events: events(5, 6, 7),
+			handle: failOn(5),
+			want:   map[string]interface{}{"last_event_id": uint(4), "failures": 4, "retry_at": now.Add(8 * time.Second), "last_error": "boom"},
+		},
+		{
+			// The failures so far were on an event that has since been handled
+			name:   "failure after progress",
+			cursor: model.OutboxCursor{Subscriber: "s", LastEventID: 4, Failures: 3},
+			events: events(5, 6, 7),
+			handle: failOn(6),
+			want:   map[string]interface{}{"last_event_id": uint(5), "failures": 1, "retry_at": now.Add(time.Second), "last_error": "boom"},
+		},
+		{
+			name:    "skipped",
+			cursor:  model.OutboxCursor{Subscriber: "s", LastEventID: 4, Failures: maxFailures - 1},
+			events:  events(5, 6, 7),
+			handle:  failOn(5),
+			want:    map[string]interface{}{"last_event_id": uint(5), "failures": 0, "retry_at": nil, "last_error": "boom"},
+			skipped: &model.OutboxFailure{Subscriber: "s", EventID: 5, Error: "boom"},
+			more:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		got := handleBatch(tt.cursor, tt.events, now, tt.handle)
+		if !reflect.DeepEqual(got.updates, tt.want) {
+			t.Errorf("%s: updates = %v, want %v", tt.name, got.updates, tt.want)
+		}
+		if !reflect.DeepEqual(got.skipped, tt.skipped) {
+			t.Errorf("%s: skipped = %+v, want %+v", tt.name, got.skipped, tt.skipped)
+		}
+		if got.more != tt.more {
+			t.Errorf("%s: more = %v, want %v", tt.name, got.more, tt.more)
+		}
+	}
+}
+
+func TestHandleBatchStopsAtFailure(t *testing.T) {
+	var handled []uint
+	handleBatch(model.OutboxCursor{Subscriber: "s"}, []model.OutboxEvent{{ID: 1}, {ID: 2}, {ID: 3}}, time.Now(),
+		func(event *model.OutboxEvent) error {
+			handled = append(handled, event.ID)
+			if event.ID == 2 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+	if want := []uint{1, 2}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{9, 256 * time.Second},
+		{10, maxRetryDelay},
+		{100, maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(tt.failures); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
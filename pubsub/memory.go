@@ -0,0 +1,48 @@
+package pubsub
+
+import "sync"
+
+// subscriberBuffer is how many events a subscriber may fall behind by
+const subscriberBuffer = 64
+
+// Memory is a broker that only reaches subscribers in this process
+type Memory struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewMemory returns an in-process broker
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish delivers the event to every current subscriber
+func (m *Memory) Publish(event Event) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe adds a subscriber
+func (m *Memory) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subscribers, ch)
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// channel is the Postgres notification channel events are sent on
+const channel = "app_events"
+
+// maxPayload is the size limit of NOTIFY payloads
+const maxPayload = 8000
+
+// Postgres is a broker that relays events through Postgres LISTEN/NOTIFY, so
+// that subscribers on every server instance receive them
+type Postgres struct {
+	db    *sql.DB
+	local *Memory
+}
+
+// NewPostgres returns a broker using the given pgx-backed database and
+// starts listening for events in the background
+func NewPostgres(db *sql.DB) *Postgres {
+	p := &Postgres{db: db, local: NewMemory()}
+	go p.listen()
+	return p
+}
+
+// Publish sends the event to every instance, including this one
+func (p *Postgres) Publish(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// NOTIFY payloads are limited to 8000 bytes. Clients can fetch the
+	// resource to make up for the missing data.
+	if len(payload) >= maxPayload && event.Data != nil {
+		event.Data = nil
+		if payload, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+	if len(payload) >= maxPayload {
+		return fmt.Errorf("%w: %s is %d bytes", ErrTooLarge, event.Type, len(payload))
+	}
+	_, err = p.db.Exec("SELECT pg_notify($1, $2)", channel, string(payload))
+	return err
+}
+
+// Subscribe adds a subscriber on this instance
+func (p *Postgres) Subscribe() (<-chan Event, func()) {
+	return p.local.Subscribe()
+}
+
+// listen holds a dedicated connection listening on the channel and hands
+// incoming events to local subscribers, reconnecting when it is lost
+func (p *Postgres) listen() {
+	for {
+		if err := p.receive(context.Background()); err != nil {
+			log.Println("Error listening for events: ", err.Error())
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (p *Postgres) receive(ctx context.Context) error {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+				log.Println("Error decoding event: ", err.Error())
+				continue
+			}
+			p.local.Publish(event)
+		}
+	})
+}
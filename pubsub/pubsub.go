@@ -0,0 +1,63 @@
+// Package pubsub fans change events out to the clients streaming them. The
+// in-memory broker serves a single server instance; the Postgres broker
+// relays events through LISTEN/NOTIFY so every instance sees them.
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrTooLarge is returned for events the broker can't carry, even without
+// their data. Publishing them again won't help.
+var ErrTooLarge = errors.New("event too large to publish")
+
+// Event is a change to a resource, addressed to the users who can see it
+type Event struct {
+	Type         string          `json:"type"` // e.g. "task.completed" or "notification.created"
+	ResourceType string          `json:"resource_type"`
+	ResourceID   uint            `json:"resource_id"`
+	ActorID      uint            `json:"actor_id,omitempty"`
+	Data         json.RawMessage `json:"data,omitempty"`
+	At           time.Time       `json:"at"`
+	UserIDs      []uint          `json:"user_ids,omitempty"` // Audience; not sent to clients
+}
+
+// For reports whether the event is addressed to the user
+func (e *Event) For(userID uint) bool {
+	for _, id := range e.UserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Broker publishes events to all subscribers, on any server instance
+type Broker interface {
+	Publish(event Event) error
+	// Subscribe returns a channel of events and a function that ends the
+	// subscription. Slow subscribers miss events rather than block others.
+	Subscribe() (<-chan Event, func())
+}
+
+var broker Broker = NewMemory()
+
+// Use replaces the broker used by Publish and Subscribe
+func Use(b Broker) {
+	broker = b
+}
+
+// Publish sends an event through the current broker
+func Publish(event Event) error {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	return broker.Publish(event)
+}
+
+// Subscribe subscribes to the current broker
+func Subscribe() (<-chan Event, func()) {
+	return broker.Subscribe()
+}
@@ -19,27 +19,58 @@ func SetupRoutes(app *fiber.App) {
 	auth.Post("/login", handler.Login)
 	auth.Post("/signup", handler.Register)
 
+	// Public
+	public := api.Group("/public")
+	public.Get("/goal/:token", handler.GetSharedGoal)
+	public.Get("/calendar/:token", handler.GetCalendar)
+	public.Get("/export/:token", handler.DownloadExport)
+
 	// User
 	user := api.Group("/user")
+	user.Get("/settings", middleware.Protected(), handler.GetSettings)
+	user.Put("/settings", middleware.Protected(), handler.UpdateSettings)
+	user.Post("/export", middleware.Protected(), handler.StartExport)
+	user.Get("/export", middleware.Protected(), handler.GetExports)
+	user.Post("/restore", middleware.Protected(), handler.RestoreAccount)
 	user.Get("/:id", middleware.Protected(), handler.GetUser)
 	// user.Post("/register", handler.CreateUser)
 	user.Patch("/:id", middleware.Protected(), handler.UpdateUser)
 	user.Delete("/:id", middleware.Protected(), handler.DeleteUser)
 
+	// Profiles
+	profile := api.Group("/profile")
+	profile.Get("/:username", middleware.Protected(), handler.GetProfile)
+	profile.Post("/:username/follow", middleware.Protected(), handler.FollowUser)
+	profile.Delete("/:username/follow", middleware.Protected(), handler.UnfollowUser)
+	profile.Get("/:username/followers", middleware.Protected(), handler.GetFollowers)
+	profile.Get("/:username/following", middleware.Protected(), handler.GetFollowing)
+
 	//TaskLists
 	taskList := api.Group("/tasklist")
 	taskList.Get("/", middleware.Protected(), handler.GetListsForUser)
 	taskList.Post("/", middleware.Protected(), handler.CreateList)
+	taskList.Post("/import", middleware.Protected(), handler.ImportTasks)
 	taskList.Patch("/:list_id", middleware.Protected(), handler.UpdateListName)
 	taskList.Delete("/:list_id", middleware.Protected(), handler.DeleteList)
+	taskList.Delete("/:list_id/completed", middleware.Protected(), handler.ClearCompletedTasks)
+	taskList.Get("/invitations", middleware.Protected(), handler.GetListInvitations)
+	taskList.Post("/invitations/:invitation_id/accept", middleware.Protected(), handler.AcceptListInvitation)
+	taskList.Post("/invitations/:invitation_id/decline", middleware.Protected(), handler.DeclineListInvitation)
+	taskList.Post("/:list_id/invite", middleware.Protected(), handler.InviteToList)
+	taskList.Get("/:list_id/members", middleware.Protected(), handler.GetListMembers)
+	taskList.Patch("/:list_id/members/:user_id", middleware.Protected(), handler.UpdateListMember)
+	taskList.Delete("/:list_id/members/:user_id", middleware.Protected(), handler.RemoveListMember)
 
 	//Tasks
 	task := api.Group("/task")
+	task.Get("/unaligned", middleware.Protected(), handler.GetUnalignedTasks)
+	task.Post("/bulk", middleware.Protected(), handler.BulkUpdateTasks)
 	task.Post("/:list_id", middleware.Protected(), handler.AddTaskToList)
 	task.Delete("/:task_id", middleware.Protected(), handler.DeleteTask)
 	// TODO: Change to PUT - backend and frontend
 	task.Patch("/:task_id", middleware.Protected(), handler.UpdateTask)
 	task.Patch("/:task_id/toggle", middleware.Protected(), handler.ToggleTask)
+	task.Patch("/:task_id/goal", middleware.Protected(), handler.LinkTaskToGoal)
 
 	//Goals
 	goal := api.Group("/goal")
@@ -49,4 +80,125 @@ func SetupRoutes(app *fiber.App) {
 	goal.Delete("/:goal_id", middleware.Protected(), handler.DeleteGoal)
 	goal.Patch("/:goal_id/toggle", middleware.Protected(), handler.ToggleGoalCompletedStatus)
 	goal.Patch("/:goal_id/:subgoal_id/toggle", middleware.Protected(), handler.ToggleSubgoalCompletedStatus)
+	goal.Post("/:goal_id/habit/:habit_id/checkin", middleware.Protected(), handler.CheckInHabit)
+	goal.Delete("/:goal_id/habit/:habit_id/checkin", middleware.Protected(), handler.UndoHabitCheckIn)
+	goal.Post("/:goal_id/checkin", middleware.Protected(), handler.SubmitCheckIn)
+	goal.Get("/:goal_id/checkin", middleware.Protected(), handler.GetCheckIns)
+	goal.Post("/:goal_id/progress", middleware.Protected(), handler.AddGoalProgress)
+	goal.Post("/:goal_id/stake", middleware.Protected(), handler.CreateStake)
+	goal.Post("/:goal_id/share", middleware.Protected(), handler.CreateShareLink)
+	goal.Get("/:goal_id/share", middleware.Protected(), handler.GetShareLinks)
+	goal.Put("/:goal_id/share/settings", middleware.Protected(), handler.UpdateShareSettings)
+	goal.Delete("/:goal_id/share/:share_id", middleware.Protected(), handler.RevokeShareLink)
+
+	//Check-ins
+	checkIn := api.Group("/checkin")
+	checkIn.Get("/missed", middleware.Protected(), handler.GetMissedCheckIns)
+	checkIn.Get("/review", middleware.Protected(), handler.GetCheckInsToReview)
+	checkIn.Patch("/:checkin_id/review", middleware.Protected(), handler.ReviewCheckIn)
+
+	//Comments and reactions
+	comment := api.Group("/comment")
+	comment.Get("/:type/:id", middleware.Protected(), handler.GetComments)
+	comment.Post("/:type/:id", middleware.Protected(), handler.CreateComment)
+	comment.Patch("/:comment_id", middleware.Protected(), handler.UpdateComment)
+	comment.Delete("/:comment_id", middleware.Protected(), handler.DeleteComment)
+
+	reaction := api.Group("/reaction")
+	reaction.Get("/:type/:id", middleware.Protected(), handler.GetReactions)
+	reaction.Post("/:type/:id", middleware.Protected(), handler.AddReaction)
+	reaction.Delete("/:type/:id", middleware.Protected(), handler.RemoveReaction)
+
+	//Groups
+	group := api.Group("/group")
+	group.Post("/", middleware.Protected(), handler.CreateGroup)
+	group.Get("/", middleware.Protected(), handler.GetGroups)
+	group.Get("/invitations", middleware.Protected(), handler.GetGroupInvitations)
+	group.Post("/invitations/:invitation_id/accept", middleware.Protected(), handler.AcceptGroupInvitation)
+	group.Post("/invitations/:invitation_id/decline", middleware.Protected(), handler.DeclineGroupInvitation)
+	group.Get("/:group_id", middleware.Protected(), handler.GetGroup)
+	group.Patch("/:group_id", middleware.Protected(), handler.UpdateGroup)
+	group.Delete("/:group_id", middleware.Protected(), handler.DeleteGroup)
+	group.Post("/:group_id/member", middleware.Protected(), handler.AddGroupMember)
+	group.Patch("/:group_id/member/:user_id", middleware.Protected(), handler.UpdateGroupMember)
+	group.Delete("/:group_id/member/:user_id", middleware.Protected(), handler.RemoveGroupMember)
+	group.Post("/:group_id/goal", middleware.Protected(), handler.CreateGroupGoal)
+	group.Get("/:group_id/goal", middleware.Protected(), handler.GetGroupGoals)
+	group.Post("/:group_id/goal/:goal_id/progress", middleware.Protected(), handler.AddGroupGoalProgress)
+	group.Get("/:group_id/feed", middleware.Protected(), handler.GetGroupFeed)
+	group.Get("/:group_id/leaderboard", middleware.Protected(), handler.GetGroupLeaderboard)
+
+	//Challenges
+	challenge := api.Group("/challenge")
+	challenge.Post("/", middleware.Protected(), handler.CreateChallenge)
+	challenge.Get("/", middleware.Protected(), handler.GetChallenges)
+	challenge.Post("/join", middleware.Protected(), handler.JoinChallenge)
+	challenge.Delete("/:challenge_id/leave", middleware.Protected(), handler.LeaveChallenge)
+	challenge.Get("/:challenge_id/leaderboard", middleware.Protected(), handler.GetChallengeLeaderboard)
+
+	//Activity
+	activity := api.Group("/activity")
+	activity.Get("/", middleware.Protected(), handler.GetActivity)
+	activity.Get("/feed", middleware.Protected(), handler.GetActivityFeed)
+
+	//Stream
+	api.Get("/stream", middleware.ProtectedStream(), handler.Stream)
+
+	//Notifications
+	notification := api.Group("/notification")
+	notification.Get("/", middleware.Protected(), handler.GetNotifications)
+	notification.Post("/read", middleware.Protected(), handler.MarkAllNotificationsRead)
+	notification.Get("/preferences", middleware.Protected(), handler.GetNotificationPreferences)
+	notification.Put("/preferences", middleware.Protected(), handler.UpdateNotificationPreferences)
+	notification.Post("/:notification_id/read", middleware.Protected(), handler.MarkNotificationRead)
+
+	//Stakes
+	stake := api.Group("/stake")
+	stake.Get("/", middleware.Protected(), handler.GetStakes)
+	stake.Get("/ledger", middleware.Protected(), handler.GetLedger)
+	stake.Post("/:stake_id/approve", middleware.Protected(), handler.ApproveStake)
+	stake.Post("/:stake_id/reject", middleware.Protected(), handler.RejectStake)
+
+	//Partners
+	partner := api.Group("/partner")
+	partner.Get("/", middleware.Protected(), handler.GetPartners)
+	partner.Post("/request", middleware.Protected(), handler.RequestPartner)
+	partner.Post("/block", middleware.Protected(), handler.BlockUser)
+	partner.Post("/nudge", middleware.Protected(), handler.SendNudge)
+	partner.Post("/:partnership_id/accept", middleware.Protected(), handler.AcceptPartner)
+	partner.Delete("/:partnership_id", middleware.Protected(), handler.RemovePartner)
+	partner.Get("/:user_id/goals", middleware.Protected(), handler.GetPartnerGoals)
+
+	//Imports
+	imports := api.Group("/import")
+	imports.Post("/", middleware.Protected(), handler.StartImport)
+	imports.Get("/", middleware.Protected(), handler.GetImports)
+	imports.Get("/:import_id", middleware.Protected(), handler.GetImport)
+
+	//Calendar feeds
+	calendar := api.Group("/calendar")
+	calendar.Post("/", middleware.Protected(), handler.CreateCalendarFeed)
+	calendar.Get("/", middleware.Protected(), handler.GetCalendarFeeds)
+	calendar.Delete("/:feed_id", middleware.Protected(), handler.RevokeCalendarFeed)
+
+	//Webhooks
+	webhook := api.Group("/webhook")
+	webhook.Post("/", middleware.Protected(), handler.CreateWebhook)
+	webhook.Get("/", middleware.Protected(), handler.GetWebhooks)
+	webhook.Patch("/:webhook_id", middleware.Protected(), handler.UpdateWebhook)
+	webhook.Delete("/:webhook_id", middleware.Protected(), handler.DeleteWebhook)
+	webhook.Get("/:webhook_id/deliveries", middleware.Protected(), handler.GetWebhookDeliveries)
+	webhook.Post("/:webhook_id/deliveries/:delivery_id/redeliver", middleware.Protected(), handler.RedeliverWebhook)
+
+	//Admin
+	admin := api.Group("/admin", middleware.Protected(), middleware.Admin())
+	admin.Get("/jobs", handler.GetJobs)
+	admin.Get("/jobs/:job_id", handler.GetJob)
+	admin.Post("/jobs/:job_id/retry", handler.RetryJob)
+
+	//Trash
+	trash := api.Group("/trash")
+	trash.Get("/", middleware.Protected(), handler.GetTrash)
+	trash.Post("/:type/:id/restore", middleware.Protected(), handler.RestoreFromTrash)
+	trash.Delete("/:type/:id", middleware.Protected(), handler.DeleteFromTrash)
 }
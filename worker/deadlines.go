@@ -0,0 +1,21 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"app/database"
+)
+
+// JobDeadlineReminders notifies goal owners once when a goal's deadline is
+// less than the lead time away
+const JobDeadlineReminders = "deadlines.remind"
+
+// DeadlineRemindersPayload is the payload of a JobDeadlineReminders job
+type DeadlineRemindersPayload struct {
+	LeadHours int `json:"lead_hours"`
+}
+
+func remindDeadlines(ctx context.Context, p DeadlineRemindersPayload) error {
+	return database.NotifyDeadlines(time.Now(), time.Duration(p.LeadHours)*time.Hour)
+}
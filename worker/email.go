@@ -0,0 +1,283 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"app/database"
+	"app/mailer"
+	"app/model"
+)
+
+// Local times at which the daily habit email and the weekly digest go out
+const (
+	habitEmailHour = 7
+	digestHour     = 8
+	digestWeekday  = time.Monday
+)
+
+// JobSendEmails sends deadline reminders, daily habit emails and weekly
+// digests that have come due
+const JobSendEmails = "emails.send"
+
+// SendScheduledEmails sends every scheduled email that is due at now, in each
+// user's own timezone and according to their email preferences
+func SendScheduledEmails(m mailer.Mailer, now time.Time) error {
+	var users []model.User
+	if err := database.DB.Where("email_reminders OR email_habits OR email_digest").Find(&users).Error; err != nil {
+		return err
+	}
+
+	// One user's failure shouldn't hold up everyone else's email
+	for i := range users {
+		user := &users[i]
+		if user.EmailReminders {
+			if err := sendReminders(m, user, now); err != nil {
+				log.Printf("Error sending reminders to user %d: %v", user.ID, err)
+			}
+		}
+		if user.EmailHabits {
+			if err := sendHabitEmail(m, user, now); err != nil {
+				log.Printf("Error sending habit email to user %d: %v", user.ID, err)
+			}
+		}
+		if user.EmailDigest {
+			if err := sendDigest(m, user, now); err != nil {
+				log.Printf("Error sending digest to user %d: %v", user.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// emailSent reports whether a scheduled email was already sent
+func emailSent(userID uint, kind, key string) bool {
+	var count int64
+	database.DB.Model(&model.EmailLog{}).Where("user_id = ? AND kind = ? AND key = ?", userID, kind, key).Count(&count)
+	return count > 0
+}
+
+// markEmailSent records that a scheduled email was sent
+func markEmailSent(userID uint, kind, key string) error {
+	entry := model.EmailLog{UserID: userID, Kind: kind, Key: key}
+	return database.DB.Where(entry).FirstOrCreate(&entry).Error
+}
+
+// displayName returns how emails address the user
+func displayName(user *model.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.Username
+}
+
+// sendReminders emails the user about goals and tasks due within their lead
+// time. Each deadline is reminded about once; moving it reminds again.
+func sendReminders(m mailer.Mailer, user *model.User, now time.Time) error {
+	type ReminderItem struct {
+		Kind, Title, Due string
+		key              string
+	}
+
+	lead := time.Duration(user.ReminderLeadHours) * time.Hour
+	if lead <= 0 {
+		lead = 24 * time.Hour
+	}
+	loc := database.UserLocation(user)
+	db := database.DB
+
+	var goals []model.Goal
+	if err := db.Where("user_id = ? AND completed = ? AND deadline > ? AND deadline <= ?", user.ID, false, now, now.Add(lead)).
+		Order("deadline ASC").Find(&goals).Error; err != nil {
+		return err
+	}
+	var tasks []model.Task
+	if err := db.Joins("JOIN task_lists ON task_lists.id = tasks.task_list_id AND task_lists.deleted_at IS NULL").
+		Where("task_lists.user_id = ? AND tasks.completed = ? AND tasks.due_date > ? AND tasks.due_date <= ?", user.ID, false, now, now.Add(lead)).
+		Order("tasks.due_date ASC").Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	var items []ReminderItem
+	for _, goal := range goals {
+		key := fmt.Sprintf("goal:%d:%d", goal.ID, goal.Deadline.Unix())
+		if !emailSent(user.ID, model.EmailReminder, key) {
+			items = append(items, ReminderItem{"Goal", goal.Name, goal.Deadline.In(loc).Format("Mon 2 Jan 15:04"), key})
+		}
+	}
+	for _, task := range tasks {
+		key := fmt.Sprintf("task:%d:%d", task.ID, task.DueDate.Unix())
+		if !emailSent(user.ID, model.EmailReminder, key) {
+			items = append(items, ReminderItem{"Task", task.Text, task.DueDate.In(loc).Format("Mon 2 Jan 15:04"), key})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	subject := "Due soon: " + items[0].Title
+	if len(items) > 1 {
+		subject = fmt.Sprintf("%d things are due soon", len(items))
+	}
+	msg, err := mailer.Compose(user.Email, subject, "reminder", map[string]interface{}{
+		"Name":  displayName(user),
+		"Items": items,
+	})
+	if err != nil {
+		return err
+	}
+	if err := m.Send(msg); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := markEmailSent(user.ID, model.EmailReminder, item.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendHabitEmail emails the user, once a day in the morning, the habits of
+// their open goals that have no check-in yet in the current period
+func sendHabitEmail(m mailer.Mailer, user *model.User, now time.Time) error {
+	type DueHabit struct {
+		Name, Frequency string
+		Streak          int
+	}
+
+	local := now.In(database.UserLocation(user))
+	if local.Hour() < habitEmailHour {
+		return nil
+	}
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	key := today.Format("2006-01-02")
+	if emailSent(user.ID, model.EmailHabits, key) {
+		return nil
+	}
+
+	db := database.DB
+	var goals []model.Goal
+	if err := db.Where("user_id = ? AND completed = ?", user.ID, false).Preload("Habits").Find(&goals).Error; err != nil {
+		return err
+	}
+	if err := database.FillHabitStreaks(db, goals); err != nil {
+		return err
+	}
+
+	var habitIDs []uint
+	for _, goal := range goals {
+		for _, habit := range goal.Habits {
+			habitIDs = append(habitIDs, habit.ID)
+		}
+	}
+	// Monthly periods reach back the furthest
+	var checkIns []model.HabitCheckIn
+	if len(habitIDs) > 0 {
+		if err := db.Where("habit_id IN ? AND date >= ?", habitIDs, model.HabitPeriod("monthly", today)).
+			Find(&checkIns).Error; err != nil {
+			return err
+		}
+	}
+
+	var due []DueHabit
+	for _, goal := range goals {
+		for _, habit := range goal.Habits {
+			period := model.HabitPeriod(habit.Frequency, today)
+			done := false
+			for _, checkIn := range checkIns {
+				if checkIn.HabitID == habit.ID && !checkIn.Date.Before(period) {
+					done = true
+					break
+				}
+			}
+			if !done {
+				due = append(due, DueHabit{habit.Name, habit.Frequency, habit.CurrentStreak})
+			}
+		}
+	}
+
+	if len(due) > 0 {
+		msg, err := mailer.Compose(user.Email, "Habits due today", "habits", map[string]interface{}{
+			"Name":   displayName(user),
+			"Habits": due,
+		})
+		if err != nil {
+			return err
+		}
+		if err := m.Send(msg); err != nil {
+			return err
+		}
+	}
+	return markEmailSent(user.ID, model.EmailHabits, key)
+}
+
+// sendDigest emails the user a summary of their week once a week: completed
+// tasks, habit streaks and open goals that are overdue or due within a week
+// without being done
+func sendDigest(m mailer.Mailer, user *model.User, now time.Time) error {
+	type DigestHabit struct {
+		Name                         string
+		CurrentStreak, LongestStreak int
+	}
+	type AtRiskGoal struct {
+		Name, Due string
+		Progress  int
+	}
+
+	loc := database.UserLocation(user)
+	local := now.In(loc)
+	if local.Weekday() != digestWeekday || local.Hour() < digestHour {
+		return nil
+	}
+	year, week := local.ISOWeek()
+	key := fmt.Sprintf("%d-W%02d", year, week)
+	if emailSent(user.ID, model.EmailDigest, key) {
+		return nil
+	}
+
+	db := database.DB
+	completed := []string{}
+	if err := db.Model(&model.Task{}).
+		Where("completed_by_id = ? AND completed = ? AND completed_at >= ?", user.ID, true, now.AddDate(0, 0, -7)).
+		Order("completed_at ASC").Pluck("text", &completed).Error; err != nil {
+		return err
+	}
+
+	var goals []model.Goal
+	if err := db.Where("user_id = ? AND completed = ?", user.ID, false).
+		Preload("Subgoals").Preload("Habits").Preload("Tasks").
+		Order("deadline ASC").Find(&goals).Error; err != nil {
+		return err
+	}
+	if err := database.FillHabitStreaks(db, goals); err != nil {
+		return err
+	}
+
+	var habits []DigestHabit
+	var atRisk []AtRiskGoal
+	for i := range goals {
+		goal := &goals[i]
+		for _, habit := range goal.Habits {
+			habits = append(habits, DigestHabit{habit.Name, habit.CurrentStreak, habit.LongestStreak})
+		}
+		progress := model.GoalProgress(goal)
+		if !goal.Deadline.IsZero() && goal.Deadline.Before(now.AddDate(0, 0, 7)) && progress < 1 {
+			atRisk = append(atRisk, AtRiskGoal{goal.Name, goal.Deadline.In(loc).Format("Mon 2 Jan 2006"), int(progress * 100)})
+		}
+	}
+
+	msg, err := mailer.Compose(user.Email, "Your week in review", "digest", map[string]interface{}{
+		"Name":           displayName(user),
+		"CompletedTasks": completed,
+		"Habits":         habits,
+		"AtRisk":         atRisk,
+	})
+	if err != nil {
+		return err
+	}
+	if err := m.Send(msg); err != nil {
+		return err
+	}
+	return markEmailSent(user.ID, model.EmailDigest, key)
+}
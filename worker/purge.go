@@ -0,0 +1,21 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"app/database"
+)
+
+// JobPurgeTrash permanently deletes soft-deleted records once they have
+// been in the trash for longer than the retention period
+const JobPurgeTrash = "trash.purge"
+
+// PurgeTrashPayload is the payload of a JobPurgeTrash job
+type PurgeTrashPayload struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+func purgeTrash(ctx context.Context, p PurgeTrashPayload) error {
+	return database.PurgeTrash(time.Now().AddDate(0, 0, -p.RetentionDays))
+}
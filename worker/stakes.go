@@ -0,0 +1,16 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"app/database"
+)
+
+// JobSettleStakes forfeits stakes on goals that missed their deadline and
+// closes claims whose dispute window has passed
+const JobSettleStakes = "stakes.settle"
+
+func settleStakes(ctx context.Context, _ struct{}) error {
+	return database.SettleStakes(time.Now())
+}
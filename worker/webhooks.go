@@ -0,0 +1,12 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"app/database"
+)
+
+func deliverWebhook(ctx context.Context, p database.DeliverWebhookPayload) error {
+	return database.DeliverWebhook(p.DeliveryID, time.Now())
+}
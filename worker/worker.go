@@ -0,0 +1,89 @@
+// Package worker holds the app's background jobs and when they run
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"app/database"
+	"app/jobs"
+	"app/mailer"
+	"app/model"
+	"app/outbox"
+)
+
+// JobPruneJobs deletes finished jobs from the queue after a while
+const JobPruneJobs = "jobs.prune"
+
+// JobPruneOutbox deletes relayed outbox events after a while
+const JobPruneOutbox = "outbox.prune"
+
+// JobPruneExports deletes data exports once their download link expires
+const JobPruneExports = "exports.prune"
+
+// schedule is a recurring job declared by Register
+type schedule struct {
+	name, spec, jobType string
+	payload             interface{}
+}
+
+// Register registers the app's job types with the job runner and declares
+// their schedules. A retention of zero days disables purging the trash.
+func Register(m mailer.Mailer, retentionDays int) error {
+	jobs.Register(JobPurgeTrash, jobs.Config{Timeout: 30 * time.Minute}, purgeTrash)
+	jobs.Register(JobSettleStakes, jobs.Config{}, settleStakes)
+	jobs.Register(JobDeadlineReminders, jobs.Config{}, remindDeadlines)
+	jobs.Register(JobSendEmails, jobs.Config{Timeout: 15 * time.Minute}, func(ctx context.Context, _ struct{}) error {
+		return SendScheduledEmails(m, time.Now())
+	})
+	jobs.Register(database.JobDeliverWebhook, jobs.Config{Timeout: time.Minute}, deliverWebhook)
+	jobs.Register(JobPruneJobs, jobs.Config{}, pruneJobs)
+	jobs.Register(JobPruneOutbox, jobs.Config{}, pruneOutbox)
+	jobs.Register(JobPruneExports, jobs.Config{}, pruneExports)
+
+	schedules := []schedule{
+		{"settle-stakes", "*/5 * * * *", JobSettleStakes, struct{}{}},
+		{"deadline-reminders", "@hourly", JobDeadlineReminders, DeadlineRemindersPayload{LeadHours: 24}},
+		{"emails", "*/15 * * * *", JobSendEmails, struct{}{}},
+		{"prune-jobs", "@daily", JobPruneJobs, struct{}{}},
+		{"prune-outbox", "@daily", JobPruneOutbox, struct{}{}},
+		{"prune-exports", "@hourly", JobPruneExports, struct{}{}},
+	}
+	if retentionDays > 0 {
+		schedules = append(schedules, schedule{"purge-trash", "@hourly", JobPurgeTrash, PurgeTrashPayload{RetentionDays: retentionDays}})
+	} else {
+		log.Println("Trash purge disabled")
+	}
+	for _, s := range schedules {
+		if err := jobs.Schedule(s.name, s.spec, s.jobType, s.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneJobs keeps succeeded jobs for a week and failed ones for a month,
+// long enough to inspect and retry them
+func pruneJobs(ctx context.Context, _ struct{}) error {
+	now := time.Now()
+	if _, err := jobs.Prune(database.DB, model.JobSucceeded, now.AddDate(0, 0, -7)); err != nil {
+		return err
+	}
+	_, err := jobs.Prune(database.DB, model.JobFailed, now.AddDate(0, 0, -30))
+	return err
+}
+
+// pruneExports deletes expired data exports and failed ones after a week
+func pruneExports(ctx context.Context, _ struct{}) error {
+	now := time.Now()
+	return database.DB.Unscoped().
+		Where("expires_at < ? OR (status = ? AND created_at < ?)", now, model.ExportFailed, now.AddDate(0, 0, -7)).
+		Delete(&model.DataExport{}).Error
+}
+
+// pruneOutbox deletes outbox events older than a week once every subscriber
+// has handled them
+func pruneOutbox(ctx context.Context, _ struct{}) error {
+	return outbox.Prune(database.DB, time.Now().AddDate(0, 0, -7))
+}